@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+	"github.com/guobinqiu/appdeployer/kube"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Shared by kube.ingress.cadir, so that certificates made locally and on deploy come from one CA
+const defaultCADir = "~/.appdeployer/ca"
+
+type CertOptions struct {
+	Dir        string
+	KeyType    string
+	CommonName string
+	CAYears    int
+	Years      int
+	Hosts      []string
+	CrtPath    string
+	KeyPath    string
+	CA         bool
+	Force      bool
+}
+
+var certOptions CertOptions
+
+func init() {
+	// set default values
+	viper.SetDefault("cert.dir", defaultCADir)
+	viper.SetDefault("cert.keytype", kube.KeyTypeRSA)
+	viper.SetDefault("cert.commonname", kube.DefaultCACommonName)
+	viper.SetDefault("cert.cayears", kube.DefaultCAYears)
+	viper.SetDefault("cert.years", 1)
+	viper.SetDefault("cert.crtpath", "./server.crt")
+	viper.SetDefault("cert.keypath", "./server.key")
+
+	certCmd.PersistentFlags().StringVar(&certOptions.Dir, "cert.dir", viper.GetString("cert.dir"), "Directory of the CA certificate ca.crt and private key ca.key. Defaults to "+defaultCADir)
+	certCmd.PersistentFlags().StringVar(&certOptions.KeyType, "cert.keytype", viper.GetString("cert.keytype"), "Key type of new certificates. Such as RSA, ECDSA and Ed25519. Defaults to RSA")
+	certCmd.PersistentFlags().StringVar(&certOptions.CommonName, "cert.commonname", viper.GetString("cert.commonname"), "Common name of a new CA. Defaults to "+kube.DefaultCACommonName)
+	certCmd.PersistentFlags().IntVar(&certOptions.CAYears, "cert.cayears", viper.GetInt("cert.cayears"), "Validity in years of the CA certificate. Defaults to 10")
+	certCmd.PersistentFlags().IntVar(&certOptions.Years, "cert.years", viper.GetInt("cert.years"), "Validity in years of server certificates, capped by the CA. Defaults to 1")
+	certCmd.PersistentFlags().StringSliceVar(&certOptions.Hosts, "cert.hosts", getStringSlice("cert.hosts"), "Domains and IP addresses covered by a new server certificate, such as example.com,*.example.com,10.0.0.1")
+	certCmd.PersistentFlags().StringVar(&certOptions.CrtPath, "cert.crtpath", viper.GetString("cert.crtpath"), "Path to the server certificate (PEM format). Defaults to ./server.crt")
+	certCmd.PersistentFlags().StringVar(&certOptions.KeyPath, "cert.keypath", viper.GetString("cert.keypath"), "Path to the server private key (PEM format). Defaults to ./server.key")
+	certCACmd.Flags().BoolVar(&certOptions.Force, "force", false, "Overwrite the existing CA. Certificates it signed will no longer be trusted")
+	certServerCmd.Flags().BoolVar(&certOptions.Force, "force", false, "Overwrite the existing server certificate and private key")
+	certRenewCmd.Flags().BoolVar(&certOptions.CA, "ca", false, "Renew the CA certificate instead of the server certificate")
+
+	certCmd.AddCommand(certCACmd)
+	certCmd.AddCommand(certServerCmd)
+	certCmd.AddCommand(certInspectCmd)
+	certCmd.AddCommand(certRenewCmd)
+	rootCmd.AddCommand(certCmd)
+}
+
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Generate, inspect and renew CA and server certificates locally",
+	Long:  "Generate, inspect and renew CA and server certificates locally. The CA is shared with self-signed certificates of kube.ingress, so clients only need to trust it once",
+}
+
+var certCACmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Generate a CA in cert.dir",
+	Run: func(cmd *cobra.Command, args []string) {
+		cm := newCertificateManager()
+
+		exist, err := helpers.IsFileExist(certFile(certOptions.Dir, "ca.crt"))
+		if err != nil {
+			panic(err)
+		}
+		if exist && !certOptions.Force {
+			panic(fmt.Sprintf("CA already exists in %s, use --force to overwrite it", certOptions.Dir))
+		}
+
+		caCert, caPrivateKey, err := cm.CreateCACertificate(certOptions.CommonName, certOptions.CAYears)
+		if err != nil {
+			panic(err)
+		}
+		if err := cm.SaveCA(caCert, caPrivateKey); err != nil {
+			panic(err)
+		}
+		fmt.Printf("CA certificate saved to %s\n", certFile(certOptions.Dir, "ca.crt"))
+	},
+}
+
+var certServerCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Generate a server certificate for cert.hosts signed by the CA, creating the CA if needed",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(certOptions.Hosts) == 0 {
+			panic("cert.hosts is required")
+		}
+		for _, path := range []string{certOptions.CrtPath, certOptions.KeyPath} {
+			exist, err := helpers.IsFileExist(helpers.ExpandUser(path))
+			if err != nil {
+				panic(err)
+			}
+			if exist && !certOptions.Force {
+				panic(fmt.Sprintf("%s already exists, use --force to overwrite it or renew to extend it", path))
+			}
+		}
+
+		cm := newCertificateManager()
+		caCert, caPrivateKey, err := cm.LoadOrCreateCA(certOptions.CommonName, certOptions.CAYears)
+		if err != nil {
+			panic(err)
+		}
+
+		serverCert, serverPrivateKey, err := cm.CreateServerCertificate(caCert, caPrivateKey, certOptions.Hosts, certOptions.Years)
+		if err != nil {
+			panic(err)
+		}
+		saveServerCertificate(cm, serverCert, serverPrivateKey)
+	},
+}
+
+var certInspectCmd = &cobra.Command{
+	Use:   "inspect [file...]",
+	Short: "Show certificates in PEM files. Defaults to the CA and cert.crtpath",
+	Run: func(cmd *cobra.Command, args []string) {
+		paths := args
+		if len(paths) == 0 {
+			paths = []string{certFile(certOptions.Dir, "ca.crt"), helpers.ExpandUser(certOptions.CrtPath)}
+		}
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				panic(fmt.Errorf("failed to read certificate file: %v", err))
+			}
+			certs, err := kube.ParseCertificatesPEM(data)
+			if err != nil {
+				panic(fmt.Errorf("%s: %v", path, err))
+			}
+			for i, cert := range certs {
+				fmt.Printf("%s [%d]\n", path, i)
+				printCertificate(cert)
+			}
+		}
+	},
+}
+
+var certRenewCmd = &cobra.Command{
+	Use:   "renew",
+	Short: "Renew the server certificate in cert.crtpath, or the CA with --ca, keeping their keys and names",
+	Run: func(cmd *cobra.Command, args []string) {
+		cm := newCertificateManager()
+		caCert, caPrivateKey, err := cm.LoadCA()
+		if err != nil {
+			panic(err)
+		}
+
+		// The key is kept, so certificates signed by the old CA are still trusted
+		if certOptions.CA {
+			caCert, err = cm.RenewCACertificate(caCert, caPrivateKey, certOptions.CAYears)
+			if err != nil {
+				panic(err)
+			}
+			if err := cm.SaveCA(caCert, caPrivateKey); err != nil {
+				panic(err)
+			}
+			fmt.Printf("CA certificate renewed until %s\n", caCert.NotAfter.Format(time.RFC3339))
+			return
+		}
+
+		crtData, err := os.ReadFile(helpers.ExpandUser(certOptions.CrtPath))
+		if err != nil {
+			panic(fmt.Errorf("failed to read certificate file: %v", err))
+		}
+		keyData, err := os.ReadFile(helpers.ExpandUser(certOptions.KeyPath))
+		if err != nil {
+			panic(fmt.Errorf("failed to read key file: %v", err))
+		}
+		serverCert, err := kube.ParseCertificatePEM(crtData)
+		if err != nil {
+			panic(err)
+		}
+		serverPrivateKey, err := kube.ParsePrivateKeyPEM(keyData)
+		if err != nil {
+			panic(err)
+		}
+
+		renewed, err := cm.RenewServerCertificate(caCert, caPrivateKey, serverCert, serverPrivateKey, certOptions.Years)
+		if err != nil {
+			panic(err)
+		}
+		saveServerCertificate(cm, renewed, serverPrivateKey)
+	},
+}
+
+func newCertificateManager() *kube.CertificateManager {
+	checkKeyType(certOptions.KeyType)
+	return &kube.CertificateManager{
+		KeyType: certOptions.KeyType,
+		CADir:   certOptions.Dir,
+	}
+}
+
+func checkKeyType(keyType string) {
+	switch strings.ToLower(keyType) {
+	case kube.KeyTypeRSA, kube.KeyTypeECDSA, kube.KeyTypeEd25519:
+	default:
+		panic(fmt.Sprintf("unsupported key type: %s", keyType))
+	}
+}
+
+func certFile(dir, name string) string {
+	return filepath.Join(helpers.ExpandUser(dir), name)
+}
+
+func saveServerCertificate(cm *kube.CertificateManager, cert []byte, privateKey crypto.Signer) {
+	keyPEM, err := cm.EncodePrivateKeyToPEM(privateKey)
+	if err != nil {
+		panic(err)
+	}
+	if err := helpers.WriteFile(helpers.ExpandUser(certOptions.KeyPath), keyPEM, 0600); err != nil {
+		panic(err)
+	}
+	if err := helpers.WriteFile(helpers.ExpandUser(certOptions.CrtPath), cm.EncodeCertificateToPEM(cert), 0644); err != nil {
+		panic(err)
+	}
+
+	parsed, err := x509.ParseCertificate(cert)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("server certificate saved to %s, valid until %s\n", certOptions.CrtPath, parsed.NotAfter.Format(time.RFC3339))
+}
+
+func printCertificate(cert *x509.Certificate) {
+	fmt.Printf("  subject:      %s\n", cert.Subject)
+	fmt.Printf("  issuer:       %s\n", cert.Issuer)
+	fmt.Printf("  serial:       %x\n", cert.SerialNumber)
+	fmt.Printf("  key:          %s\n", describePublicKey(cert.PublicKey))
+	fmt.Printf("  ca:           %t\n", cert.IsCA)
+	if len(cert.DNSNames) > 0 {
+		fmt.Printf("  dns names:    %s\n", strings.Join(cert.DNSNames, ", "))
+	}
+	if len(cert.IPAddresses) > 0 {
+		var ips []string
+		for _, ip := range cert.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		fmt.Printf("  ip addresses: %s\n", strings.Join(ips, ", "))
+	}
+	fmt.Printf("  not before:   %s\n", cert.NotBefore.Format(time.RFC3339))
+
+	remaining := time.Until(cert.NotAfter)
+	if remaining < 0 {
+		fmt.Printf("  not after:    %s (expired %d days ago)\n", cert.NotAfter.Format(time.RFC3339), int(-remaining.Hours()/24))
+	} else {
+		fmt.Printf("  not after:    %s (expires in %d days)\n", cert.NotAfter.Format(time.RFC3339), int(remaining.Hours()/24))
+	}
+}
+
+func describePublicKey(publicKey interface{}) string {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", publicKey)
+	}
+}
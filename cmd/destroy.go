@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guobinqiu/appdeployer/kube"
+	"github.com/spf13/cobra"
+)
+
+type DestroyOptions struct {
+	KeepPVC         bool
+	KeepNamespace   bool
+	DeleteNamespace bool
+	Yes             bool
+}
+
+var destroyOptions DestroyOptions
+
+func init() {
+	destroyCmd.Flags().BoolVar(&destroyOptions.KeepPVC, "keep-pvc", false, "Keep the persistent volume claim of app")
+	destroyCmd.Flags().BoolVar(&destroyOptions.KeepNamespace, "keep-namespace", false, "Keep the namespace of app")
+	destroyCmd.Flags().BoolVar(&destroyOptions.DeleteNamespace, "delete-namespace", false, "Delete the namespace of app even if it was not created by appdeployer")
+	destroyCmd.Flags().BoolVarP(&destroyOptions.Yes, "yes", "y", false, "Skip confirmation")
+
+	kubeCmd.AddCommand(destroyCmd)
+}
+
+// A resource to delete, listed in dependency order
+type destroyStep struct {
+	description string
+	delete      func() error
+}
+
+var destroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Delete all kubernetes resources created for app",
+	Run: func(cmd *cobra.Command, args []string) {
+		setDefaultOptions()
+		setKubeconfigOptions()
+
+		clientset := newClientset()
+		dynamicClient := newDynamicClient()
+
+		//TODO handle timeout or cancel
+		ctx := context.TODO()
+
+		name := defaultOptions.AppName
+		namespace := kubeOptions.Namespace
+
+		steps := []destroyStep{
+			{fmt.Sprintf("ingress, service and deployment %s", kube.CanaryName(name)), func() error {
+				return kube.DeleteCanary(clientset, ctx, name, namespace)
+			}},
+			{"hpa " + name, func() error {
+				return kube.DeleteHPA(clientset, ctx, name, namespace, false)
+			}},
+			{"pdb " + name, func() error {
+				return kube.DeletePDB(clientset, ctx, name, namespace, false)
+			}},
+			{fmt.Sprintf("networkpolicies %s, %s", kube.DefaultDenyPolicyName(name), kube.AllowPolicyName(name)), func() error {
+				return kube.DeleteNetworkPolicies(clientset, ctx, name, namespace, false)
+			}},
+			{"ingress " + name, func() error {
+				return kube.DeleteIngress(clientset, ctx, name, namespace, false)
+			}},
+			{"httproutes and referencegrant " + name, func() error {
+				return kube.DeleteHTTPRoute(dynamicClient, ctx, name, namespace, false)
+			}},
+			{"certificate " + name, func() error {
+				return kube.DeleteCertificate(dynamicClient, ctx, name, namespace, false)
+			}},
+			{"secret tls-" + name, func() error {
+				return kube.DeleteTlsSecret(clientset, ctx, kube.IngressOptions{Name: name, Namespace: namespace})
+			}},
+			{"service " + name, func() error {
+				return kube.DeleteService(clientset, ctx, name, namespace, false)
+			}},
+			{"deployment " + name, func() error {
+				return kube.DeleteDeployment(clientset, ctx, kube.DeploymentOptions{Name: name, Namespace: namespace})
+			}},
+			{fmt.Sprintf("deployments %s, %s (blue/green)", kube.DeploymentName(name, kube.ColorBlue), kube.DeploymentName(name, kube.ColorGreen)), func() error {
+				return kube.DeleteColorDeployments(clientset, ctx, kube.DeploymentOptions{Name: name, Namespace: namespace})
+			}},
+			{"daemonset " + name, func() error {
+				return kube.DeleteDaemonSet(clientset, ctx, name, namespace, false)
+			}},
+			{"job " + name, func() error {
+				return kube.DeleteJob(clientset, ctx, name, namespace, false)
+			}},
+			{"cronjob " + name, func() error {
+				return kube.DeleteCronJob(clientset, ctx, name, namespace, false)
+			}},
+			{fmt.Sprintf("statefulset %s and service %s", name, kube.HeadlessServiceName(name)), func() error {
+				return kube.DeleteStatefulSet(clientset, ctx, name, namespace, false)
+			}},
+			{fmt.Sprintf("configmaps and secrets %s-config-*, %s-secret-*", name, name), func() error {
+				return kube.DeleteConfig(clientset, ctx, name, namespace)
+			}},
+		}
+		if !destroyOptions.KeepPVC {
+			steps = append(steps, destroyStep{"pvc " + name, func() error {
+				return kube.DeletePVC(clientset, ctx, kube.PVCOptions{Name: name, Namespace: namespace})
+			}}, destroyStep{fmt.Sprintf("pvcs data-%s-* (statefulset)", name), func() error {
+				return kube.DeleteStatefulSetPVCs(clientset, ctx, name, namespace)
+			}})
+		}
+		steps = append(steps,
+			destroyStep{fmt.Sprintf("role and rolebinding %s, clusterrole and clusterrolebinding %s", name, kube.ClusterRoleName(name, namespace)), func() error {
+				return kube.DeleteRBAC(clientset, ctx, name, namespace, false)
+			}},
+			destroyStep{"serviceaccount " + name, func() error {
+				return kube.DeleteServiceAccount(clientset, ctx, kube.ServiceAccountOptions{Name: name, Namespace: namespace})
+			}},
+			destroyStep{"secret docker-" + name, func() error {
+				return kube.DeleteDockerSecret(clientset, ctx, kube.DockerSecretOptions{Name: name, Namespace: namespace})
+			}},
+		)
+		if destroyOptions.KeepNamespace && destroyOptions.DeleteNamespace {
+			panic("--keep-namespace and --delete-namespace cannot be used together")
+		}
+		// A namespace shared with other apps is only deleted when asked to
+		deleteNamespace := destroyOptions.DeleteNamespace
+		if !destroyOptions.KeepNamespace && !deleteNamespace {
+			managed, err := kube.NamespaceManaged(clientset, ctx, namespace)
+			if err != nil {
+				panic(err)
+			}
+			deleteNamespace = managed || namespace == name
+			if !deleteNamespace {
+				fmt.Printf("Namespace %s is kept as it was not created by appdeployer, use --delete-namespace to delete it\n", namespace)
+			}
+		}
+		if deleteNamespace {
+			steps = append(steps, destroyStep{"namespace " + namespace, func() error {
+				return kube.DeleteNamespace(clientset, ctx, namespace)
+			}})
+		}
+
+		fmt.Printf("The following resources in namespace %s will be deleted:\n", namespace)
+		for _, step := range steps {
+			fmt.Printf("  %s\n", step.description)
+		}
+		if !destroyOptions.Yes && !confirm("Continue?") {
+			fmt.Println("Aborted")
+			return
+		}
+
+		for _, step := range steps {
+			if err := step.delete(); err != nil {
+				panic(err)
+			}
+		}
+	},
+}
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
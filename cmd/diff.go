@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/guobinqiu/appdeployer/kube"
+	"github.com/spf13/cobra"
+)
+
+// Exit code when live resources differ from the desired ones.
+// 1 is taken by command errors and 2 by panics
+const diffExitCode = 3
+
+func init() {
+	kubeCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show differences between app resources in kubernetes cluster and the ones to be deployed",
+	Long:  fmt.Sprintf("Show differences between app resources in kubernetes cluster and the ones to be deployed. Exits with %d when there are differences", diffExitCode),
+	Run: func(cmd *cobra.Command, args []string) {
+		setDefaultOptions()
+		setDockerImageOptions()
+		setKubeconfigOptions()
+		setKubeOptions()
+
+		clientset := newClientset()
+
+		//TODO handle timeout or cancel
+		ctx := context.TODO()
+
+		// Compare with the color the next blue/green deploy would create
+		if kubeOptions.deploymentOptions.Strategy == kube.StrategyBlueGreen {
+			liveColor, err := kube.LiveColor(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace)
+			if err != nil {
+				panic(err)
+			}
+			setColorOptions(kube.OtherColor(liveColor))
+		}
+
+		objs, err := buildObjects(false)
+		if err != nil {
+			panic(err)
+		}
+
+		changed, err := kube.Diff(clientset, newDynamicClient(), ctx, objs, os.Stdout)
+		if err != nil {
+			panic(err)
+		}
+		if changed {
+			os.Exit(diffExitCode)
+		}
+	},
+}
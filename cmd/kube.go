@@ -3,24 +3,57 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/guobinqiu/appdeployer/docker"
 	"github.com/guobinqiu/appdeployer/helpers"
 	"github.com/guobinqiu/appdeployer/kube"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 type KubeOptions struct {
-	Kubeconfig        string
-	Namespace         string
-	ingressOptions    kube.IngressOptions
-	serviceOptions    kube.ServiceOptions
-	deploymentOptions kube.DeploymentOptions
-	hpaOptions        kube.HPAOptions
-	pvcOptions        kube.PVCOptions
+	Kubeconfig           string
+	Namespace            string
+	RolloutTimeout       time.Duration
+	Workload             string
+	Routing              string
+	InitContainers       []string // names of [kube.initcontainer.<name>] sections in run order
+	ServicePorts         []string // name:port[:targetPort[:nodePort]] besides the http port
+	IngressRules         []string // [host]/path[:pathType[:servicePort]]
+	IngressAnnotations   []string // key=value
+	GatewayBackends      []string // service:port:weight
+	HPAMetrics           []string // pods:metric:averageValue, object:apiVersion/kind/name:metric:value[:targetType] or external:metric:value[:targetType]
+	HPAScaleUp           hpaScalingFlags
+	HPAScaleDown         hpaScalingFlags
+	AutomountToken       bool
+	RBACClusterScope     bool
+	ingressOptions       kube.IngressOptions
+	serviceOptions       kube.ServiceOptions
+	deploymentOptions    kube.DeploymentOptions
+	hpaOptions           kube.HPAOptions
+	pdbOptions           kube.PDBOptions
+	networkPolicyOptions kube.NetworkPolicyOptions
+	pvcOptions           kube.PVCOptions
+	configOptions        kube.ConfigOptions
+	cronJobOptions       kube.CronJobOptions
+	daemonSetOptions     kube.DaemonSetOptions
+	gatewayOptions       kube.GatewayOptions
+}
+
+// Raw scale up or scale down behavior of the HPA, empty values keep the kubernetes defaults
+type hpaScalingFlags struct {
+	StabilizationWindow string
+	Policies            []string // type:value:period
+	SelectPolicy        string
 }
 
 var dockerOptions docker.DockerOptions
@@ -33,14 +66,40 @@ func init() {
 	viper.SetDefault("docker.registry", docker.DOCKERHUB)
 	viper.SetDefault("docker.tag", "latest")
 	viper.SetDefault("kube.kubeconfig", "~/.kube/config")
+	viper.SetDefault("kube.rollout.timeout", "5m")
 	viper.SetDefault("kube.ingress.tls", false)
 	viper.SetDefault("kube.ingress.selfsigned", false)
 	viper.SetDefault("kube.ingress.selfsignedyears", 1)
 	viper.SetDefault("kube.service.port", 8000)
+	viper.SetDefault("kube.service.type", "ClusterIP")
+	viper.SetDefault("kube.service.sessionaffinity", "None")
+	viper.SetDefault("kube.service.externaltrafficpolicy", "Cluster")
+	viper.SetDefault("kube.ingress.serviceport", "http")
+	viper.SetDefault("kube.routing", kube.RoutingIngress)
+	viper.SetDefault("kube.ingress.issuerkind", "ClusterIssuer")
+	viper.SetDefault("kube.ingress.minvalidity", "720h")
+	viper.SetDefault("kube.ingress.selfsignedkeytype", kube.KeyTypeRSA)
+	viper.SetDefault("kube.ingress.cadir", defaultCADir)
+	viper.SetDefault("kube.ingress.issuertimeout", "5m")
+	viper.SetDefault("kube.gateway.httpssectionname", "https")
+	viper.SetDefault("kube.ingress.classname", kube.IngressClassNginx)
 	viper.SetDefault("kube.deployment.replicas", 1)
 	viper.SetDefault("kube.deployment.port", 8000)
+	viper.SetDefault("kube.deployment.strategy", kube.StrategyRollingUpdate)
+	viper.SetDefault("kube.deployment.bluegreen.scaledowndelay", "5m")
+	viper.SetDefault("kube.workload", kube.WorkloadDeployment)
+	viper.SetDefault("kube.daemonset.maxunavailable", "1")
+	viper.SetDefault("kube.job.backofflimit", 6)
+	viper.SetDefault("kube.cronjob.concurrencypolicy", "forbid")
+	viper.SetDefault("kube.cronjob.successfuljobshistorylimit", 3)
+	viper.SetDefault("kube.cronjob.failedjobshistorylimit", 1)
+	viper.SetDefault("kube.configmap.mountpath", "/app/config")
+	viper.SetDefault("kube.secret.mountpath", "/app/secrets")
+	viper.SetDefault("kube.deployment.canary.steps", "10,25,50,100")
+	viper.SetDefault("kube.deployment.canary.pause", "1m")
 	viper.SetDefault("kube.deployment.rollingupdate.maxsurge", "1")
 	viper.SetDefault("kube.deployment.rollingupdate.maxunavailable", "0")
+	viper.SetDefault("kube.deployment.progressdeadlineseconds", 600)
 	viper.SetDefault("kube.deployment.livenessprobe.enabled", false)
 	viper.SetDefault("kube.deployment.livenessprobe.type", kube.ProbeTypeHTTPGet)
 	viper.SetDefault("kube.deployment.livenessprobe.path", "/")
@@ -65,67 +124,139 @@ func init() {
 	viper.SetDefault("kube.hpa.minreplicas", 1)
 	viper.SetDefault("kube.hpa.maxreplicas", 10)
 	viper.SetDefault("kube.hpa.cpurate", 50)
+	viper.SetDefault("kube.pdb.enabled", false)
+	viper.SetDefault("kube.serviceaccount.automounttoken", true)
+	viper.SetDefault("kube.rbac.clusterscope", false)
+	viper.SetDefault("kube.networkpolicy.enabled", false)
+	viper.SetDefault("kube.networkpolicy.ingressnamespaces", "ingress-nginx")
+	viper.SetDefault("kube.networkpolicy.egress.enabled", false)
 	viper.SetDefault("kube.pvc.accessmode", "readwriteonce")
 	viper.SetDefault("kube.pvc.storageclassname", "openebs-hostpath")
 	viper.SetDefault("kube.pvc.storagesize", "1G")
 
 	// docker
-	kubeCmd.Flags().StringVar(&dockerOptions.Dockerconfig, "docker.dockerconfig", viper.GetString("docker.dockerconfig"), "Path to docker configuration. Defaults to ~/.docker/config.json")
-	kubeCmd.Flags().StringVar(&dockerOptions.Dockerfile, "docker.dockerfile", viper.GetString("docker.dockerfile"), "Path to Dockerfile for building image. Defaults to appdir/Dockerfile")
-	kubeCmd.Flags().StringVar(&dockerOptions.Registry, "docker.registry", viper.GetString("docker.registry"), "URL for docker registry. Defaults to https://index.docker.io/v1/")
-	kubeCmd.Flags().StringVar(&dockerOptions.Username, "docker.username", viper.GetString("docker.username"), "Username for docker registry")
-	kubeCmd.Flags().StringVar(&dockerOptions.Password, "docker.password", viper.GetString("docker.password"), "Password for docker registry")
-	kubeCmd.Flags().StringVar(&dockerOptions.Repository, "docker.repository", viper.GetString("docker.repository"), "Repository for docker registry")
-	kubeCmd.Flags().StringVar(&dockerOptions.Tag, "docker.tag", viper.GetString("docker.tag"), "Tag for docker registry. Defaults to latest")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Dockerconfig, "docker.dockerconfig", viper.GetString("docker.dockerconfig"), "Path to docker configuration. Defaults to ~/.docker/config.json")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Dockerfile, "docker.dockerfile", viper.GetString("docker.dockerfile"), "Path to Dockerfile for building image. Defaults to appdir/Dockerfile")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Registry, "docker.registry", viper.GetString("docker.registry"), "URL for docker registry. Defaults to https://index.docker.io/v1/")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Username, "docker.username", viper.GetString("docker.username"), "Username for docker registry")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Password, "docker.password", viper.GetString("docker.password"), "Password for docker registry")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Repository, "docker.repository", viper.GetString("docker.repository"), "Repository for docker registry")
+	kubeCmd.PersistentFlags().StringVar(&dockerOptions.Tag, "docker.tag", viper.GetString("docker.tag"), "Tag for docker registry. Defaults to latest")
 
 	//kube
-	kubeCmd.Flags().StringVar(&kubeOptions.Kubeconfig, "kube.kubeconfig", viper.GetString("kube.kubeconfig"), "Path to kubernetes configuration. Defaults to ~/.kube/config")
-	kubeCmd.Flags().StringVar(&kubeOptions.Namespace, "kube.namespace", viper.GetString("kube.namespace"), "Namespace for app resources. Defaults to appname")
-	kubeCmd.Flags().StringVar(&kubeOptions.ingressOptions.Host, "kube.ingress.host", viper.GetString("kube.ingress.host"), "Host for app ingress. Defaults to appName.com")
-	kubeCmd.Flags().BoolVar(&kubeOptions.ingressOptions.TLS, "kube.ingress.tls", viper.GetBool("kube.ingress.tls"), "Enable or disable TLS for app host. Defaults to false")
-	kubeCmd.Flags().BoolVar(&kubeOptions.ingressOptions.SelfSigned, "kube.ingress.selfsigned", viper.GetBool("kube.ingress.selfsigned"), "Enable or disable self-signed certificate. Defaults to false")
-	kubeCmd.Flags().IntVar(&kubeOptions.ingressOptions.SelfSignedYears, "kube.ingress.selfsignedyears", viper.GetInt("kube.ingress.selfsignedyears"), "Validity of self-signed certificate. Defaults to 1 year")
-	kubeCmd.Flags().StringVar(&kubeOptions.ingressOptions.CrtPath, "kube.ingress.crtpath", viper.GetString("kube.ingress.crtpath"), "Path to .crt file (PEM format) for non self-signed certificate")
-	kubeCmd.Flags().StringVar(&kubeOptions.ingressOptions.KeyPath, "kube.ingress.keypath", viper.GetString("kube.ingress.keypath"), "Path to .key file (PEM format) for non self-signed certificate")
-	kubeCmd.Flags().Int32Var(&kubeOptions.serviceOptions.Port, "kube.service.port", viper.GetInt32("kube.service.port"), "Port for app service. Defaults to 8000")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.Replicas, "kube.deployment.replicas", viper.GetInt32("kube.deployment.replicas"), "Number of app pods. Defaults to 1")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.Port, "kube.deployment.port", viper.GetInt32("kube.deployment.port"), "Container port for each app pod. Defaults to 8000, as same as service port")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.RollingUpdate.MaxSurge, "kube.deployment.rollingupdate.maxsurge", viper.GetString("kube.deployment.rollingupdate.maxsurge"), "MaxSurge for rolling update app pods. Defaults to 1")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.RollingUpdate.MaxUnavailable, "kube.deployment.rollingupdate.maxunavailable", viper.GetString("kube.deployment.rollingupdate.maxunavailable"), "MaxUnavailable for rolling update app pods. Defaults to 0")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.Quota.CPULimit, "kube.deployment.quota.cpulimit", viper.GetString("kube.deployment.quota.cpulimit"), "CPU limit for each app container (one pod one container)")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.Quota.MemLimit, "kube.deployment.quota.memlimit", viper.GetString("kube.deployment.quota.memlimit"), "Memory limit for each app container (one pod one container)")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.Quota.CPURequest, "kube.deployment.quota.cpurequest", viper.GetString("kube.deployment.quota.cpurequest"), "CPU request for each app container (one pod one container)")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.Quota.MemRequest, "kube.deployment.quota.memrequest", viper.GetString("kube.deployment.quota.memrequest"), "Memory request for each app container (one pod one container)")
-	kubeCmd.Flags().BoolVar(&kubeOptions.deploymentOptions.LivenessProbe.Enabled, "kube.deployment.livenessprobe.enabled", viper.GetBool("kube.deployment.livenessprobe.enabled"), "Enable or disable liveness probe for each app container (one pod one container). Defaults to false")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Type, "kube.deployment.livenessprobe.type", viper.GetString("kube.deployment.livenessprobe.type"), "Type of liveness probe for each app container (one pod one container). Such as HTTPGet, TCPSocket and Exec. Defaults to HTTPGet")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Path, "kube.deployment.livenessprobe.path", viper.GetString("kube.deployment.livenessprobe.path"), "Path of liveness probe for each app container (one pod one container). Correspond to HTTPGet type. Defaults to /")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Scheme, "kube.deployment.livenessprobe.scheme", viper.GetString("kube.deployment.livenessprobe.scheme"), "Scheme of liveness probe for each app container (one pod one container). Correspond to HTTPGet type. Such as HTTP and HTTPS. Defaults to HTTP")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Command, "kube.deployment.livenessprobe.command", viper.GetString("kube.deployment.livenessprobe.command"), "Command of liveness probe for each app container (one pod one container). Correspond to Exec type")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.InitialDelaySeconds, "kube.deployment.livenessprobe.initialdelayseconds", viper.GetInt32("kube.deployment.livenessprobe.initialdelayseconds"), "Initial delay seconds of liveness probe for each app container (one pod one container). Defaults to 0")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.TimeoutSeconds, "kube.deployment.livenessprobe.timeoutseconds", viper.GetInt32("kube.deployment.livenessprobe.timeoutseconds"), "Timeout seconds of liveness probe for each app container (one pod one container). Defaults to 1")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.PeriodSeconds, "kube.deployment.livenessprobe.periodseconds", viper.GetInt32("kube.deployment.livenessprobe.periodseconds"), "Period seconds of liveness probe for each app container (one pod one container). Defaults to 10")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.SuccessThreshold, "kube.deployment.livenessprobe.successthreshold", viper.GetInt32("kube.deployment.livenessprobe.successthreshold"), "Success threshold of liveness probe for each app container (one pod one container). Defaults to 1")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.FailureThreshold, "kube.deployment.livenessprobe.failurethreshold", viper.GetInt32("kube.deployment.livenessprobe.failurethreshold"), "Failure threshold of liveness probe for each app container (one pod one container). Defaults to 3")
-	kubeCmd.Flags().BoolVar(&kubeOptions.deploymentOptions.ReadinessProbe.Enabled, "kube.deployment.readinessprobe.enabled", viper.GetBool("kube.deployment.readinessprobe.enabled"), "Enable or disable readiness probe for each app container (one pod one container)")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Type, "kube.deployment.readinessprobe.type", viper.GetString("kube.deployment.readinessprobe.type"), "Type of readiness probe for each app container (one pod one container). Such as HTTPGet, TCPSocket and Exec. Defaults to HTTPGet")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Path, "kube.deployment.readinessprobe.path", viper.GetString("kube.deployment.readinessprobe.path"), "Path of readiness probe for each app container (one pod one container). Correspond to HTTPGet type. Defaults to /")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Scheme, "kube.deployment.readinessprobe.scheme", viper.GetString("kube.deployment.readinessprobe.scheme"), "Scheme of readiness probe for each app container (one pod one container). Correspond to HTTPGet type. Such as HTTP and HTTPS. Defaults to HTTP")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Command, "kube.deployment.readinessprobe.command", viper.GetString("kube.deployment.readinessprobe.command"), "Command of readiness probe for each app container (one pod one container). Correspond to Exec type")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.InitialDelaySeconds, "kube.deployment.readinessprobe.initialdelayseconds", viper.GetInt32("kube.deployment.readinessprobe.initialdelayseconds"), "Initial delay seconds of readiness probe for each app container (one pod one container). Defaults to 0")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.TimeoutSeconds, "kube.deployment.readinessprobe.timeoutseconds", viper.GetInt32("kube.deployment.readinessprobe.timeoutseconds"), "Timeout seconds of readiness probe for each app container (one pod one container). Defaults to 1")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.PeriodSeconds, "kube.deployment.readinessprobe.periodseconds", viper.GetInt32("kube.deployment.readinessprobe.periodseconds"), "Period seconds of readiness probe for each app container (one pod one container). Defaults to 10")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.SuccessThreshold, "kube.deployment.readinessprobe.successthreshold", viper.GetInt32("kube.deployment.readinessprobe.successthreshold"), "Success threshold of readiness probe for each app container (one pod one container). Defaults to 1")
-	kubeCmd.Flags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.FailureThreshold, "kube.deployment.readinessprobe.failurethreshold", viper.GetInt32("kube.deployment.readinessprobe.failurethreshold"), "Failure threshold of readiness probe for each app container (one pod one container). Defaults to 3")
-	kubeCmd.Flags().BoolVar(&kubeOptions.deploymentOptions.VolumeMount.Enabled, "kube.deployment.volumemount.enabled", viper.GetBool("kube.deployment.volumemount.enabled"), "Enable or disable volume mount for each app pod. Defaults to false")
-	kubeCmd.Flags().StringVar(&kubeOptions.deploymentOptions.VolumeMount.MountPath, "kube.deployment.volumemount.mountpath", viper.GetString("kube.deployment.volumemount.mountpath"), "Path of volume mount for each app pod. Defaults to /app/data")
-	kubeCmd.Flags().BoolVar(&kubeOptions.hpaOptions.Enabled, "kube.hpa.enabled", viper.GetBool("kube.hpa.enabled"), "Enable or disable HPA (Horizontal Pod Autoscaler) for app pods. Defaults to false")
-	kubeCmd.Flags().Int32Var(&kubeOptions.hpaOptions.MinReplicas, "kube.hpa.minreplicas", viper.GetInt32("kube.hpa.minreplicas"), "Number of minimum pods for HPA (Horizontal Pod Autoscaler). Defaults to 1")
-	kubeCmd.Flags().Int32Var(&kubeOptions.hpaOptions.MaxReplicas, "kube.hpa.maxreplicas", viper.GetInt32("kube.hpa.maxreplicas"), "Number of maximum pods for HPA (Horizontal Pod Autoscaler). Defaults to 10")
-	kubeCmd.Flags().Int32Var(&kubeOptions.hpaOptions.CPURate, "kube.hpa.cpurate", viper.GetInt32("kube.hpa.cpurate"), "Average CPU utilization for HPA (Horizontal Pod Autoscaler). Defaults to 50")
-	kubeCmd.Flags().StringVar(&kubeOptions.pvcOptions.AccessMode, "kube.pvc.accessmode", viper.GetString("kube.pvc.accessmode"), "Access mode of persistent storage for pod volumn mount. Such as ReadWriteOnce, ReadOnlyMany and ReadWriteMany. Defaults to ReadWriteOnce")
-	kubeCmd.Flags().StringVar(&kubeOptions.pvcOptions.StorageClassName, "kube.pvc.storageclassname", viper.GetString("kube.pvc.storageclassname"), "Classname of persistent storage for pod volumn mount. Defaults to openebs-hostpath")
-	kubeCmd.Flags().StringVar(&kubeOptions.pvcOptions.StorageSize, "kube.pvc.storagesize", viper.GetString("kube.pvc.storagesize"), "Size of persistent storage for pod volumn mount. Defaults to 1G")
-	kubeCmd.Flags().StringSliceVarP(&kubeOptions.deploymentOptions.EnvVars, "env", "e", nil, "Set environment variables in the form of key=value")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.Kubeconfig, "kube.kubeconfig", viper.GetString("kube.kubeconfig"), "Path to kubernetes configuration. Defaults to ~/.kube/config")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.Namespace, "kube.namespace", viper.GetString("kube.namespace"), "Namespace for app resources. Defaults to appname")
+	kubeCmd.PersistentFlags().DurationVar(&kubeOptions.RolloutTimeout, "kube.rollout.timeout", viper.GetDuration("kube.rollout.timeout"), "Timeout for waiting app rollout to complete. Set to 0 to skip waiting. Defaults to 5m")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.Workload, "kube.workload", viper.GetString("kube.workload"), "Kind of workload running app pods. Such as Deployment, StatefulSet, DaemonSet, Job and CronJob. StatefulSet gives each replica a stable identity and its own volume. DaemonSet runs one pod on each node. Job and CronJob run batch apps without service, ingress and HPA. Defaults to Deployment")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.daemonSetOptions.MaxUnavailable, "kube.daemonset.maxunavailable", viper.GetString("kube.daemonset.maxunavailable"), "MaxUnavailable for rolling update app daemonset pods. Defaults to 1")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.daemonSetOptions.HostNetwork, "kube.daemonset.hostnetwork", viper.GetBool("kube.daemonset.hostnetwork"), "Enable or disable host networking for app daemonset pods. Defaults to false")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.daemonSetOptions.HostPaths, "kube.daemonset.hostpaths", getStringSlice("kube.daemonset.hostpaths"), "Host paths mounted into the app container of daemonset in the form of hostPath:mountPath[:ro]")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.cronJobOptions.Job.BackoffLimit, "kube.job.backofflimit", viper.GetInt32("kube.job.backofflimit"), "Number of retries before a job of app is considered failed. Defaults to 6")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.cronJobOptions.Schedule, "kube.cronjob.schedule", viper.GetString("kube.cronjob.schedule"), "Cron schedule of app, such as '*/5 * * * *'. Required for cronjob workload")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.cronJobOptions.ConcurrencyPolicy, "kube.cronjob.concurrencypolicy", viper.GetString("kube.cronjob.concurrencypolicy"), "How to treat concurrent runs of app cronjob. Such as Allow, Forbid and Replace. Defaults to Forbid")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.cronJobOptions.SuccessfulJobsHistoryLimit, "kube.cronjob.successfuljobshistorylimit", viper.GetInt32("kube.cronjob.successfuljobshistorylimit"), "Number of successful finished jobs of app cronjob to keep. Defaults to 3")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.cronJobOptions.FailedJobsHistoryLimit, "kube.cronjob.failedjobshistorylimit", viper.GetInt32("kube.cronjob.failedjobshistorylimit"), "Number of failed finished jobs of app cronjob to keep. Defaults to 1")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.Routing, "kube.routing", viper.GetString("kube.routing"), "How app is exposed outside the cluster. Such as Ingress and Gateway. Gateway attaches an HTTPRoute to an existing Gateway API gateway, sharing hosts, paths and TLS options with ingress. Defaults to Ingress")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.gatewayOptions.Gateway, "kube.gateway.name", viper.GetString("kube.gateway.name"), "Name of the parent gateway of app httproute. Required for gateway routing")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.gatewayOptions.GatewayNamespace, "kube.gateway.namespace", viper.GetString("kube.gateway.namespace"), "Namespace of the parent gateway. Defaults to the app namespace")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.gatewayOptions.SectionName, "kube.gateway.sectionname", viper.GetString("kube.gateway.sectionname"), "Gateway listener serving plain http. Defaults to all listeners, or http for redirecting to https when kube.ingress.tls is enabled")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.gatewayOptions.HTTPSSectionName, "kube.gateway.httpssectionname", viper.GetString("kube.gateway.httpssectionname"), "Gateway listener serving https with the tls secret of app when kube.ingress.tls is enabled. Defaults to https")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.GatewayBackends, "kube.gateway.backends", getStringSlice("kube.gateway.backends"), "Services the httproute splits traffic between in the form of service:port:weight, such as hellogo:8000:90,hellogo-next:8000:10. Defaults to all traffic to the app service")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.Host, "kube.ingress.host", viper.GetString("kube.ingress.host"), "Host for app ingress. Defaults to appName.com")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.ingressOptions.TLS, "kube.ingress.tls", viper.GetBool("kube.ingress.tls"), "Enable or disable TLS for app host. Defaults to false")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.Issuer, "kube.ingress.issuer", viper.GetString("kube.ingress.issuer"), "Name of the cert-manager issuer signing the certificate of app hosts, instead of self-signed certificate or certificate files")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.IssuerKind, "kube.ingress.issuerkind", viper.GetString("kube.ingress.issuerkind"), "Kind of the cert-manager issuer. Such as ClusterIssuer and Issuer. Defaults to ClusterIssuer")
+	kubeCmd.PersistentFlags().DurationVar(&kubeOptions.ingressOptions.IssuerTimeout, "kube.ingress.issuertimeout", viper.GetDuration("kube.ingress.issuertimeout"), "Timeout for waiting the cert-manager certificate to be ready. Defaults to 5m")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.ingressOptions.SelfSigned, "kube.ingress.selfsigned", viper.GetBool("kube.ingress.selfsigned"), "Enable or disable self-signed certificate. Defaults to false")
+	kubeCmd.PersistentFlags().IntVar(&kubeOptions.ingressOptions.SelfSignedYears, "kube.ingress.selfsignedyears", viper.GetInt("kube.ingress.selfsignedyears"), "Validity in years of self-signed server certificate. Defaults to 1 year")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.SelfSignedKeyType, "kube.ingress.selfsignedkeytype", viper.GetString("kube.ingress.selfsignedkeytype"), "Key type of self-signed server certificate. Such as RSA, ECDSA and Ed25519. Defaults to RSA")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.CADir, "kube.ingress.cadir", viper.GetString("kube.ingress.cadir"), "Directory of the CA signing self-signed certificates, created on first use and reused by later deploys. Defaults to "+defaultCADir)
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.CrtPath, "kube.ingress.crtpath", viper.GetString("kube.ingress.crtpath"), "Path to .crt file (PEM format) for non self-signed certificate")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.KeyPath, "kube.ingress.keypath", viper.GetString("kube.ingress.keypath"), "Path to .key file (PEM format) for non self-signed certificate")
+	kubeCmd.PersistentFlags().DurationVar(&kubeOptions.ingressOptions.MinValidity, "kube.ingress.minvalidity", viper.GetDuration("kube.ingress.minvalidity"), "Minimum remaining validity of the certificate in crtpath. Certificates expiring sooner are rejected. Defaults to 720h")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.ServicePort, "kube.ingress.serviceport", viper.GetString("kube.ingress.serviceport"), "Name of app service port the ingress routes traffic to. Defaults to http")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.ingressOptions.ClassName, "kube.ingress.classname", viper.GetString("kube.ingress.classname"), "Ingress class of app ingress. Nginx annotations are only added for nginx. Defaults to nginx")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.IngressAnnotations, "kube.ingress.annotations", getStringSlice("kube.ingress.annotations"), "Extra annotations of app ingress in the form of key=value, overriding the default ones")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.IngressRules, "kube.ingress.rules", getStringSlice("kube.ingress.rules"), "Routing rules of app ingress in the form of [host]/path[:pathType[:servicePort]], such as api.example.com/v1:prefix,/healthz:exact. Host defaults to kube.ingress.host, path type to Prefix and service port to kube.ingress.serviceport. Defaults to / of kube.ingress.host")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.serviceOptions.Port, "kube.service.port", viper.GetInt32("kube.service.port"), "Port for app service. Defaults to 8000")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.serviceOptions.NodePort, "kube.service.nodeport", viper.GetInt32("kube.service.nodeport"), "Node port of the http port for NodePort and LoadBalancer app service. Defaults to 0, allocated by the cluster")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.ServicePorts, "kube.service.ports", getStringSlice("kube.service.ports"), "Named ports of app service besides http in the form of name:port[:targetPort[:nodePort]], such as metrics:9090,grpc:9000:50051. Target ports are also declared on the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.serviceOptions.Type, "kube.service.type", viper.GetString("kube.service.type"), "Type of app service. Such as ClusterIP, NodePort, LoadBalancer and Headless. Defaults to ClusterIP")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.serviceOptions.SessionAffinity, "kube.service.sessionaffinity", viper.GetString("kube.service.sessionaffinity"), "Session affinity of app service. Such as None and ClientIP. Defaults to None")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.serviceOptions.ExternalTrafficPolicy, "kube.service.externaltrafficpolicy", viper.GetString("kube.service.externaltrafficpolicy"), "External traffic policy of NodePort and LoadBalancer app service. Such as Cluster and Local. Defaults to Cluster")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.Replicas, "kube.deployment.replicas", viper.GetInt32("kube.deployment.replicas"), "Number of app pods. Defaults to 1")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.Port, "kube.deployment.port", viper.GetInt32("kube.deployment.port"), "Container port for each app pod. Defaults to 8000, as same as service port")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.Strategy, "kube.deployment.strategy", viper.GetString("kube.deployment.strategy"), "Strategy for updating app pods. Such as RollingUpdate, BlueGreen and Canary. Defaults to RollingUpdate")
+	kubeCmd.PersistentFlags().DurationVar(&kubeOptions.deploymentOptions.BlueGreen.ScaleDownDelay, "kube.deployment.bluegreen.scaledowndelay", viper.GetDuration("kube.deployment.bluegreen.scaledowndelay"), "Time to keep the old color running after switching service in blue/green strategy, so that it can be switched back instantly. Defaults to 5m")
+	kubeCmd.PersistentFlags().IntSliceVar(&kubeOptions.deploymentOptions.Canary.Steps, "kube.deployment.canary.steps", getIntSlice("kube.deployment.canary.steps"), "Percentages of traffic shifted to the canary step by step in canary strategy. Defaults to 10,25,50,100")
+	kubeCmd.PersistentFlags().DurationVar(&kubeOptions.deploymentOptions.Canary.Pause, "kube.deployment.canary.pause", viper.GetDuration("kube.deployment.canary.pause"), "Pause after each canary step before checking the canary is healthy. Defaults to 1m")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.RollingUpdate.MaxSurge, "kube.deployment.rollingupdate.maxsurge", viper.GetString("kube.deployment.rollingupdate.maxsurge"), "MaxSurge for rolling update app pods. Defaults to 1")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.RollingUpdate.MaxUnavailable, "kube.deployment.rollingupdate.maxunavailable", viper.GetString("kube.deployment.rollingupdate.maxunavailable"), "MaxUnavailable for rolling update app pods. Defaults to 0")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.ProgressDeadlineSeconds, "kube.deployment.progressdeadlineseconds", viper.GetInt32("kube.deployment.progressdeadlineseconds"), "Seconds for app deployment to make progress before it is considered failed. Defaults to 600")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.Quota.CPULimit, "kube.deployment.quota.cpulimit", viper.GetString("kube.deployment.quota.cpulimit"), "CPU limit for the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.Quota.MemLimit, "kube.deployment.quota.memlimit", viper.GetString("kube.deployment.quota.memlimit"), "Memory limit for the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.Quota.CPURequest, "kube.deployment.quota.cpurequest", viper.GetString("kube.deployment.quota.cpurequest"), "CPU request for the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.Quota.MemRequest, "kube.deployment.quota.memrequest", viper.GetString("kube.deployment.quota.memrequest"), "Memory request for the app container")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.deploymentOptions.LivenessProbe.Enabled, "kube.deployment.livenessprobe.enabled", viper.GetBool("kube.deployment.livenessprobe.enabled"), "Enable or disable liveness probe for the app container. Defaults to false")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Type, "kube.deployment.livenessprobe.type", viper.GetString("kube.deployment.livenessprobe.type"), "Type of liveness probe for the app container. Such as HTTPGet, TCPSocket and Exec. Defaults to HTTPGet")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Path, "kube.deployment.livenessprobe.path", viper.GetString("kube.deployment.livenessprobe.path"), "Path of liveness probe for the app container. Correspond to HTTPGet type. Defaults to /")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Scheme, "kube.deployment.livenessprobe.scheme", viper.GetString("kube.deployment.livenessprobe.scheme"), "Scheme of liveness probe for the app container. Correspond to HTTPGet type. Such as HTTP and HTTPS. Defaults to HTTP")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.LivenessProbe.Command, "kube.deployment.livenessprobe.command", viper.GetString("kube.deployment.livenessprobe.command"), "Command of liveness probe for the app container. Correspond to Exec type")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.InitialDelaySeconds, "kube.deployment.livenessprobe.initialdelayseconds", viper.GetInt32("kube.deployment.livenessprobe.initialdelayseconds"), "Initial delay seconds of liveness probe for the app container. Defaults to 0")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.TimeoutSeconds, "kube.deployment.livenessprobe.timeoutseconds", viper.GetInt32("kube.deployment.livenessprobe.timeoutseconds"), "Timeout seconds of liveness probe for the app container. Defaults to 1")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.PeriodSeconds, "kube.deployment.livenessprobe.periodseconds", viper.GetInt32("kube.deployment.livenessprobe.periodseconds"), "Period seconds of liveness probe for the app container. Defaults to 10")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.SuccessThreshold, "kube.deployment.livenessprobe.successthreshold", viper.GetInt32("kube.deployment.livenessprobe.successthreshold"), "Success threshold of liveness probe for the app container. Defaults to 1")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.LivenessProbe.FailureThreshold, "kube.deployment.livenessprobe.failurethreshold", viper.GetInt32("kube.deployment.livenessprobe.failurethreshold"), "Failure threshold of liveness probe for the app container. Defaults to 3")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.deploymentOptions.ReadinessProbe.Enabled, "kube.deployment.readinessprobe.enabled", viper.GetBool("kube.deployment.readinessprobe.enabled"), "Enable or disable readiness probe for the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Type, "kube.deployment.readinessprobe.type", viper.GetString("kube.deployment.readinessprobe.type"), "Type of readiness probe for the app container. Such as HTTPGet, TCPSocket and Exec. Defaults to HTTPGet")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Path, "kube.deployment.readinessprobe.path", viper.GetString("kube.deployment.readinessprobe.path"), "Path of readiness probe for the app container. Correspond to HTTPGet type. Defaults to /")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Scheme, "kube.deployment.readinessprobe.scheme", viper.GetString("kube.deployment.readinessprobe.scheme"), "Scheme of readiness probe for the app container. Correspond to HTTPGet type. Such as HTTP and HTTPS. Defaults to HTTP")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.ReadinessProbe.Command, "kube.deployment.readinessprobe.command", viper.GetString("kube.deployment.readinessprobe.command"), "Command of readiness probe for the app container. Correspond to Exec type")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.InitialDelaySeconds, "kube.deployment.readinessprobe.initialdelayseconds", viper.GetInt32("kube.deployment.readinessprobe.initialdelayseconds"), "Initial delay seconds of readiness probe for the app container. Defaults to 0")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.TimeoutSeconds, "kube.deployment.readinessprobe.timeoutseconds", viper.GetInt32("kube.deployment.readinessprobe.timeoutseconds"), "Timeout seconds of readiness probe for the app container. Defaults to 1")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.PeriodSeconds, "kube.deployment.readinessprobe.periodseconds", viper.GetInt32("kube.deployment.readinessprobe.periodseconds"), "Period seconds of readiness probe for the app container. Defaults to 10")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.SuccessThreshold, "kube.deployment.readinessprobe.successthreshold", viper.GetInt32("kube.deployment.readinessprobe.successthreshold"), "Success threshold of readiness probe for the app container. Defaults to 1")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.deploymentOptions.ReadinessProbe.FailureThreshold, "kube.deployment.readinessprobe.failurethreshold", viper.GetInt32("kube.deployment.readinessprobe.failurethreshold"), "Failure threshold of readiness probe for the app container. Defaults to 3")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.deploymentOptions.VolumeMount.Enabled, "kube.deployment.volumemount.enabled", viper.GetBool("kube.deployment.volumemount.enabled"), "Enable or disable volume mount for each app pod. Defaults to false")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.deploymentOptions.VolumeMount.MountPath, "kube.deployment.volumemount.mountpath", viper.GetString("kube.deployment.volumemount.mountpath"), "Path of volume mount for each app pod. Defaults to /app/data")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.deploymentOptions.SharedVolumes, "kube.deployment.sharedvolumes", getStringSlice("kube.deployment.sharedvolumes"), "EmptyDir volumes in the form of name:path, mounted into the app container and shared with sidecars")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.InitContainers, "kube.deployment.initcontainers", getStringSlice("kube.deployment.initcontainers"), "Names of init containers run in order before the app container starts, each declared in a [kube.initcontainer.<name>] section of config.ini")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.hpaOptions.Enabled, "kube.hpa.enabled", viper.GetBool("kube.hpa.enabled"), "Enable or disable HPA (Horizontal Pod Autoscaler) for app pods. Defaults to false")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.hpaOptions.MinReplicas, "kube.hpa.minreplicas", viper.GetInt32("kube.hpa.minreplicas"), "Number of minimum pods for HPA (Horizontal Pod Autoscaler). Defaults to 1")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.hpaOptions.MaxReplicas, "kube.hpa.maxreplicas", viper.GetInt32("kube.hpa.maxreplicas"), "Number of maximum pods for HPA (Horizontal Pod Autoscaler). Defaults to 10")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.hpaOptions.CPURate, "kube.hpa.cpurate", viper.GetInt32("kube.hpa.cpurate"), "Average CPU utilization in percent of CPU requests for HPA (Horizontal Pod Autoscaler), 0 to not scale on CPU. Defaults to 50")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.hpaOptions.CPUValue, "kube.hpa.cpuvalue", viper.GetString("kube.hpa.cpuvalue"), "Average CPU usage per pod for HPA, such as 500m. Replaces kube.hpa.cpurate and does not need CPU requests")
+	kubeCmd.PersistentFlags().Int32Var(&kubeOptions.hpaOptions.MemoryRate, "kube.hpa.memoryrate", viper.GetInt32("kube.hpa.memoryrate"), "Average memory utilization in percent of memory requests for HPA, 0 to not scale on memory. Defaults to 0")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.hpaOptions.MemoryValue, "kube.hpa.memoryvalue", viper.GetString("kube.hpa.memoryvalue"), "Average memory usage per pod for HPA, such as 512Mi. Replaces kube.hpa.memoryrate and does not need memory requests")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.HPAMetrics, "kube.hpa.metrics", getStringSlice("kube.hpa.metrics"), "Custom metrics for HPA served by a metrics adapter, such as pods:http_requests_per_second:100, object:networking.k8s.io/v1/Ingress/hellogo:requests_per_second:2k or external:queue_messages{queue=orders}:30. Target type of object and external metrics can be appended as value or averagevalue")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.AutomountToken, "kube.serviceaccount.automounttoken", viper.GetBool("kube.serviceaccount.automounttoken"), "Mount the ServiceAccount token into app pods. Turn it off for apps that do not call the Kubernetes API. Defaults to true")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.RBACClusterScope, "kube.rbac.clusterscope", viper.GetBool("kube.rbac.clusterscope"), "Grant the rules of [kube.rbac.rule.<name>] sections through a ClusterRole in all namespaces instead of a Role in the app namespace. Defaults to false")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.pdbOptions.Enabled, "kube.pdb.enabled", viper.GetBool("kube.pdb.enabled"), "Enable or disable PDB (PodDisruptionBudget) limiting how many app pods node drains can evict at once. Defaults to false")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.pdbOptions.MinAvailable, "kube.pdb.minavailable", viper.GetString("kube.pdb.minavailable"), "Number or percentage of app pods that must stay available during evictions, such as 2 or 50%. Cannot be used with kube.pdb.maxunavailable")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.pdbOptions.MaxUnavailable, "kube.pdb.maxunavailable", viper.GetString("kube.pdb.maxunavailable"), "Number or percentage of app pods that can be unavailable during evictions, such as 1 or 25%. Defaults to 1 when kube.pdb.minavailable is not set")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.networkPolicyOptions.Enabled, "kube.networkpolicy.enabled", viper.GetBool("kube.networkpolicy.enabled"), "Enable or disable NetworkPolicies denying traffic to app pods except from the ingress controller, other replicas and the allowed apps and CIDRs. Defaults to false")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.networkPolicyOptions.IngressNamespaces, "kube.networkpolicy.ingressnamespaces", getStringSlice("kube.networkpolicy.ingressnamespaces"), "Namespaces of the ingress controller or gateway pods, allowed to reach the ports served through ingress. Defaults to ingress-nginx")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.networkPolicyOptions.AllowApps, "kube.networkpolicy.allowapps", getStringSlice("kube.networkpolicy.allowapps"), "Other apps allowed to reach all service ports of app in the form of [namespace/]app. Namespace defaults to the app name")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.networkPolicyOptions.AllowCIDRs, "kube.networkpolicy.allowcidrs", getStringSlice("kube.networkpolicy.allowcidrs"), "IP ranges allowed to reach app in the form of cidr[:port], such as 10.0.0.0/8 or 192.168.1.0/24:8000. All service ports are allowed without a port")
+	kubeCmd.PersistentFlags().BoolVar(&kubeOptions.networkPolicyOptions.Egress, "kube.networkpolicy.egress.enabled", viper.GetBool("kube.networkpolicy.egress.enabled"), "Restrict outgoing traffic of app pods to DNS, other replicas and the allowed apps and CIDRs. Defaults to false")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.networkPolicyOptions.EgressApps, "kube.networkpolicy.egress.allowapps", getStringSlice("kube.networkpolicy.egress.allowapps"), "Other apps app pods can reach in the form of [namespace/]app. Namespace defaults to the app name")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.networkPolicyOptions.EgressCIDRs, "kube.networkpolicy.egress.allowcidrs", getStringSlice("kube.networkpolicy.egress.allowcidrs"), "IP ranges app pods can reach in the form of cidr[:port], such as 10.0.0.0/8:5432. All ports are allowed without a port")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.HPAScaleUp.StabilizationWindow, "kube.hpa.scaleup.stabilizationwindow", viper.GetString("kube.hpa.scaleup.stabilizationwindow"), "Time window of past recommendations the HPA looks back on before scaling up, such as 1m. Defaults to 0s")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.HPAScaleUp.Policies, "kube.hpa.scaleup.policies", getStringSlice("kube.hpa.scaleup.policies"), "Policies limiting how fast the HPA scales up in the form of type:value:period, such as pods:4:60s,percent:100:15s")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.HPAScaleUp.SelectPolicy, "kube.hpa.scaleup.selectpolicy", viper.GetString("kube.hpa.scaleup.selectpolicy"), "Which of the scale up policies applies. Such as Max, Min and Disabled, Disabled never scales up. Defaults to Max")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.HPAScaleDown.StabilizationWindow, "kube.hpa.scaledown.stabilizationwindow", viper.GetString("kube.hpa.scaledown.stabilizationwindow"), "Time window of past recommendations the HPA looks back on before scaling down, such as 10m. Defaults to 5m")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.HPAScaleDown.Policies, "kube.hpa.scaledown.policies", getStringSlice("kube.hpa.scaledown.policies"), "Policies limiting how fast the HPA scales down in the form of type:value:period, such as percent:10:60s")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.HPAScaleDown.SelectPolicy, "kube.hpa.scaledown.selectpolicy", viper.GetString("kube.hpa.scaledown.selectpolicy"), "Which of the scale down policies applies. Such as Max, Min and Disabled, Disabled never scales down. Defaults to Max")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.pvcOptions.AccessMode, "kube.pvc.accessmode", viper.GetString("kube.pvc.accessmode"), "Access mode of persistent storage for pod volumn mount. Such as ReadWriteOnce, ReadOnlyMany and ReadWriteMany. Defaults to ReadWriteOnce")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.pvcOptions.StorageClassName, "kube.pvc.storageclassname", viper.GetString("kube.pvc.storageclassname"), "Classname of persistent storage for pod volumn mount. Defaults to openebs-hostpath")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.pvcOptions.StorageSize, "kube.pvc.storagesize", viper.GetString("kube.pvc.storagesize"), "Size of persistent storage for pod volumn mount. Defaults to 1G")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.configOptions.ConfigMap.EnvFile, "kube.configmap.envfile", viper.GetString("kube.configmap.envfile"), "Env file relative to appdir, whose KEY=VALUE lines are put into a ConfigMap and injected into the app container with envFrom")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.configOptions.ConfigMap.Files, "kube.configmap.files", getStringSlice("kube.configmap.files"), "Files relative to appdir, which are put into a ConfigMap and mounted into the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.configOptions.ConfigMap.MountPath, "kube.configmap.mountpath", viper.GetString("kube.configmap.mountpath"), "Path to mount files of ConfigMap. Defaults to /app/config")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.configOptions.Secret.EnvFile, "kube.secret.envfile", viper.GetString("kube.secret.envfile"), "Env file relative to appdir, whose KEY=VALUE lines are put into a Secret and injected into the app container with envFrom")
+	kubeCmd.PersistentFlags().StringSliceVar(&kubeOptions.configOptions.Secret.Files, "kube.secret.files", getStringSlice("kube.secret.files"), "Files relative to appdir, which are put into a Secret and mounted into the app container")
+	kubeCmd.PersistentFlags().StringVar(&kubeOptions.configOptions.Secret.MountPath, "kube.secret.mountpath", viper.GetString("kube.secret.mountpath"), "Path to mount files of Secret. Defaults to /app/secrets")
+	kubeCmd.PersistentFlags().StringArrayVarP(&kubeOptions.deploymentOptions.EnvVars, "env", "e", nil, "Set environment variables of the app container in the form of key=value, several separated by commas. Write \\, for a comma inside a value, such as -e OUTPUTS=es\\,stdout. The value can also reference secret://<name>/<key>, configmap://<name>/<key>, field://<pod field> or resource://<container resource>")
 }
 
 var kubeCmd = &cobra.Command{
@@ -134,6 +265,7 @@ var kubeCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		setDefaultOptions()
 		setDockerOptions()
+		setKubeconfigOptions()
 		setKubeOptions()
 
 		gitPull()
@@ -160,96 +292,319 @@ var kubeCmd = &cobra.Command{
 		dockerservice.Close()
 
 		// Create a kubernetes client by the specified kubeconfig
-		config, err := clientcmd.BuildConfigFromFlags("", kubeOptions.Kubeconfig)
-		if err != nil {
+		clientset := newClientset()
+
+		// Update or create kubernetes resource objects
+		if err := kube.CreateOrUpdateNamespace(clientset, ctx, kubeOptions.Namespace); err != nil {
 			panic(err)
 		}
 
-		clientset, err := kubernetes.NewForConfig(config)
-		if err != nil {
+		if err := kube.CreateOrUpdateDockerSecret(clientset, ctx, dockerSecretOptions()); err != nil {
 			panic(err)
 		}
 
-		// Update or create kubernetes resource objects
-		if err := kube.CreateOrUpdateNamespace(clientset, ctx, kubeOptions.Namespace); err != nil {
+		if err := kube.CreateOrUpdateServiceAccount(clientset, ctx, serviceAccountOptions()); err != nil {
 			panic(err)
 		}
 
-		if err := kube.CreateOrUpdateDockerSecret(clientset, ctx, kube.DockerSecretOptions{
-			Name:          defaultOptions.AppName,
-			Namespace:     kubeOptions.Namespace,
-			DockerOptions: dockerOptions,
-		}); err != nil {
-			panic(err)
+		// Permissions are granted before pods start, since apps may call the api on startup
+		if rbacOptions := rbacOptions(); len(rbacOptions.Rules) > 0 {
+			if err := kube.CreateOrUpdateRBAC(clientset, ctx, rbacOptions); err != nil {
+				panic(err)
+			}
+		} else {
+			if err := kube.DeleteRBAC(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+				panic(err)
+			}
 		}
 
-		if err := kube.CreateOrUpdateServiceAccount(clientset, ctx, kube.ServiceAccountOptions{
-			Name:      defaultOptions.AppName,
-			Namespace: kubeOptions.Namespace,
-		}); err != nil {
+		// Pods are restarted through the hash annotation when config changes
+		configHash, err := kube.CreateOrUpdateConfig(clientset, ctx, kubeOptions.configOptions)
+		if err != nil {
 			panic(err)
 		}
+		kubeOptions.deploymentOptions.ConfigHash = configHash
 
-		if kubeOptions.deploymentOptions.VolumeMount.Enabled {
-			kubeOptions.pvcOptions.Name = defaultOptions.AppName
-			kubeOptions.pvcOptions.Namespace = kubeOptions.Namespace
-			if err := kube.CreateOrUpdatePVC(clientset, ctx, kubeOptions.pvcOptions); err != nil {
+		// Canary shifts traffic to the new version step by step before promoting it to the app deployment.
+		// The first deploy has nothing to compare with and falls back to rolling update
+		canary := false
+		if kubeOptions.deploymentOptions.Strategy == kube.StrategyCanary {
+			canary, err = kube.DeploymentExists(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace)
+			if err != nil {
 				panic(err)
 			}
-		} else {
-			kubeOptions.deploymentOptions.Name = defaultOptions.AppName
-			kubeOptions.deploymentOptions.Namespace = kubeOptions.Namespace
-			kubeOptions.deploymentOptions.Image = dockerOptions.Image()
-			if err := kube.DeleteDeployment(clientset, ctx, kubeOptions.deploymentOptions); err != nil {
+		}
+
+		// Replicas of a statefulset get their own pvc from volumeClaimTemplates
+		statefulSet := kubeOptions.Workload == kube.WorkloadStatefulSet
+		if !statefulSet {
+			if kubeOptions.deploymentOptions.VolumeMount.Enabled {
+				if err := kube.CreateOrUpdatePVC(clientset, ctx, kubeOptions.pvcOptions); err != nil {
+					panic(err)
+				}
+			} else {
+				if err := kube.DeletePVC(clientset, ctx, kubeOptions.pvcOptions); err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		// Batch workloads run to completion and are not exposed through service, ingress and hpa
+		if isBatchWorkload() {
+			deployBatch(clientset, ctx)
+			return
+		}
+
+		// Blue/green deploys the idle color next to the live one and switches the service once it is ready
+		blueGreen := kubeOptions.deploymentOptions.Strategy == kube.StrategyBlueGreen
+		liveColor := ""
+		if blueGreen {
+			liveColor, err = kube.LiveColor(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace)
+			if err != nil {
 				panic(err)
 			}
+			setColorOptions(kube.OtherColor(liveColor))
+		}
 
-			kubeOptions.hpaOptions.Name = defaultOptions.AppName
-			kubeOptions.hpaOptions.Namespace = kubeOptions.Namespace
-			if err := kube.DeletePVC(clientset, ctx, kubeOptions.hpaOptions); err != nil {
+		if canary {
+			if err := kube.DeployCanary(clientset, newDynamicClient(), ctx, kube.CanaryOptions{
+				Deployment: kubeOptions.deploymentOptions,
+				Service:    kubeOptions.serviceOptions,
+				Ingress:    kubeOptions.ingressOptions,
+				Routing:    kubeOptions.Routing,
+				Gateway:    gatewayOptions(),
+				Timeout:    kubeOptions.RolloutTimeout,
+			}); err != nil {
 				panic(err)
 			}
 		}
 
-		kubeOptions.deploymentOptions.Name = defaultOptions.AppName
-		kubeOptions.deploymentOptions.Namespace = kubeOptions.Namespace
-		kubeOptions.deploymentOptions.Image = dockerOptions.Image()
-		if err := kube.CreateOrUpdateDeployment(clientset, ctx, kubeOptions.deploymentOptions); err != nil {
-			panic(err)
+		switch kubeOptions.Workload {
+		case kube.WorkloadStatefulSet:
+			if err := kube.CreateOrUpdateStatefulSet(clientset, ctx, statefulSetOptions()); err != nil {
+				panic(err)
+			}
+		case kube.WorkloadDaemonSet:
+			if err := kube.CreateOrUpdateDaemonSet(clientset, ctx, daemonSetOptions()); err != nil {
+				panic(err)
+			}
+		default:
+			if err := kube.CreateOrUpdateDeployment(clientset, ctx, kubeOptions.deploymentOptions); err != nil {
+				panic(err)
+			}
+		}
+
+		if blueGreen {
+			scaleUpColor(clientset, ctx, kubeOptions.deploymentOptions.Color)
+			if err := kube.WaitForRollout(clientset, ctx, rolloutOptions()); err != nil {
+				panic(err)
+			}
+			if err := kube.CheckColorAvailable(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, kubeOptions.deploymentOptions.Color); err != nil {
+				panic(err)
+			}
 		}
 
-		kubeOptions.serviceOptions.Name = defaultOptions.AppName
-		kubeOptions.serviceOptions.Namespace = kubeOptions.Namespace
-		kubeOptions.serviceOptions.TargetPort = kubeOptions.deploymentOptions.Port
 		if err := kube.CreateOrUpdateService(clientset, ctx, kubeOptions.serviceOptions); err != nil {
 			panic(err)
 		}
 
-		kubeOptions.ingressOptions.Name = defaultOptions.AppName
-		kubeOptions.ingressOptions.Namespace = kubeOptions.Namespace
-		if err := kube.CreateOrUpdateIngress(clientset, ctx, kubeOptions.ingressOptions); err != nil {
-			panic(err)
+		if blueGreen {
+			if err := kube.DeletePlainDeployment(clientset, ctx, kubeOptions.deploymentOptions); err != nil {
+				panic(err)
+			}
 		}
 
+		applyRouting(clientset, ctx)
+
 		if kubeOptions.hpaOptions.Enabled {
-			kubeOptions.hpaOptions.Name = defaultOptions.AppName
-			kubeOptions.hpaOptions.Namespace = kubeOptions.Namespace
 			if err := kube.CreateOrUpdateHPA(clientset, ctx, kubeOptions.hpaOptions); err != nil {
 				panic(err)
 			}
 		} else {
-			kubeOptions.hpaOptions.Name = defaultOptions.AppName
-			kubeOptions.hpaOptions.Namespace = kubeOptions.Namespace
-			if err := kube.DeleteHPA(clientset, ctx, kubeOptions.hpaOptions); err != nil {
+			if err := kube.DeleteHPA(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, false); err != nil {
+				panic(err)
+			}
+		}
+
+		if kubeOptions.pdbOptions.Enabled {
+			if err := kube.CreateOrUpdatePDB(clientset, ctx, kubeOptions.pdbOptions); err != nil {
+				panic(err)
+			}
+		} else {
+			if err := kube.DeletePDB(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+				panic(err)
+			}
+		}
+
+		if kubeOptions.networkPolicyOptions.Enabled {
+			if err := kube.CreateOrUpdateNetworkPolicies(clientset, ctx, kubeOptions.networkPolicyOptions); err != nil {
+				panic(err)
+			}
+		} else {
+			if err := kube.DeleteNetworkPolicies(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+				panic(err)
+			}
+		}
+
+		if blueGreen {
+			// Keep the old color for a while so that it can be switched back instantly
+			if liveColor != "" {
+				delay := kubeOptions.deploymentOptions.BlueGreen.ScaleDownDelay
+				fmt.Printf("keeping deployment %s for %s, run `appdeploy kube switch` to switch back\n", kube.DeploymentName(defaultOptions.AppName, liveColor), delay)
+				time.Sleep(delay)
+				if err := kube.ScaleDownColor(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, liveColor); err != nil {
+					panic(err)
+				}
+			}
+			return
+		}
+
+		// Wait for the new pods to become available
+		if kubeOptions.RolloutTimeout > 0 {
+			if err := kube.WaitForRollout(clientset, ctx, rolloutOptions()); err != nil {
+				panic(err)
+			}
+		}
+
+		deleteOtherWorkloads(clientset, ctx)
+
+		// Remove deployments left over from blue/green strategy
+		if err := kube.DeleteColorDeployments(clientset, ctx, kubeOptions.deploymentOptions); err != nil {
+			panic(err)
+		}
+
+		// The promoted app deployment serves all traffic now
+		if canary {
+			if err := kube.DeleteCanary(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace); err != nil {
 				panic(err)
 			}
 		}
 	},
 }
 
-func setDockerOptions() {
-	dockerOptions.AppDir = defaultOptions.AppDir
+// Expose app through the ingress or the httproute, and remove the other one after switching kube.routing
+func applyRouting(clientset *kubernetes.Clientset, ctx context.Context) {
+	dynamicClient := newDynamicClient()
+
+	if kubeOptions.Routing == kube.RoutingGateway {
+		if err := kube.CreateOrUpdateHTTPRoute(clientset, dynamicClient, ctx, gatewayOptions()); err != nil {
+			panic(err)
+		}
+		if err := kube.DeleteIngress(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+			panic(err)
+		}
+	} else {
+		if err := kube.CreateOrUpdateIngress(clientset, dynamicClient, ctx, kubeOptions.ingressOptions); err != nil {
+			panic(err)
+		}
+		if err := kube.DeleteHTTPRoute(dynamicClient, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+			panic(err)
+		}
+	}
 
+	// Stop cert-manager from renewing the tls secret once the issuer is no longer used
+	if !useIssuer() {
+		if err := kube.DeleteCertificate(dynamicClient, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func useIssuer() bool {
+	return kubeOptions.ingressOptions.TLS && !helpers.IsBlank(kubeOptions.ingressOptions.Issuer)
+}
+
+// Run app as a job or cronjob, and remove workloads of the other kinds
+func deployBatch(clientset *kubernetes.Clientset, ctx context.Context) {
+	if kubeOptions.Workload == kube.WorkloadJob {
+		if err := kube.CreateOrReplaceJob(clientset, ctx, jobOptions()); err != nil {
+			panic(err)
+		}
+	} else {
+		if err := kube.CreateOrUpdateCronJob(clientset, ctx, cronJobOptions()); err != nil {
+			panic(err)
+		}
+	}
+
+	deleteOtherWorkloads(clientset, ctx)
+	if err := kube.DeleteColorDeployments(clientset, ctx, kubeOptions.deploymentOptions); err != nil {
+		panic(err)
+	}
+
+	// Job pods carry the app label too, a pdb or network policies left from a long running workload would still select them
+	if err := kube.DeletePDB(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+	if err := kube.DeleteNetworkPolicies(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+
+	// Batch workloads serve no traffic, so the service, ingress and hpa of a long running workload go too
+	dynamicClient := newDynamicClient()
+	if err := kube.DeleteHPA(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+	if err := kube.DeleteIngress(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+	if err := kube.DeleteHTTPRoute(dynamicClient, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+	if err := kube.DeleteCertificate(dynamicClient, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+	if err := kube.DeleteService(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, true); err != nil {
+		panic(err)
+	}
+
+	// Wait for the job to complete
+	if kubeOptions.Workload == kube.WorkloadJob && kubeOptions.RolloutTimeout > 0 {
+		if err := kube.WaitForRollout(clientset, ctx, rolloutOptions()); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Remove workloads of the other kinds left over from switching kube.workload
+func deleteOtherWorkloads(clientset *kubernetes.Clientset, ctx context.Context) {
+	name := defaultOptions.AppName
+	namespace := kubeOptions.Namespace
+
+	deletes := []struct {
+		workload string
+		delete   func() error
+	}{
+		{kube.WorkloadDeployment, func() error {
+			return kube.DeletePlainDeployment(clientset, ctx, kubeOptions.deploymentOptions)
+		}},
+		{kube.WorkloadStatefulSet, func() error {
+			return kube.DeleteStatefulSet(clientset, ctx, name, namespace, true)
+		}},
+		{kube.WorkloadDaemonSet, func() error {
+			return kube.DeleteDaemonSet(clientset, ctx, name, namespace, true)
+		}},
+		{kube.WorkloadJob, func() error {
+			return kube.DeleteJob(clientset, ctx, name, namespace, true)
+		}},
+		{kube.WorkloadCronJob, func() error {
+			return kube.DeleteCronJob(clientset, ctx, name, namespace, true)
+		}},
+	}
+	for _, d := range deletes {
+		if d.workload == kubeOptions.Workload {
+			continue
+		}
+		if err := d.delete(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func isBatchWorkload() bool {
+	return kubeOptions.Workload == kube.WorkloadJob || kubeOptions.Workload == kube.WorkloadCronJob
+}
+
+func setDockerOptions() {
 	dockerOptions.Dockerconfig = helpers.ExpandUser(dockerOptions.Dockerconfig)
 	exist, err := helpers.IsFileExist(dockerOptions.Dockerconfig)
 	if err != nil {
@@ -259,6 +614,13 @@ func setDockerOptions() {
 		panic("dockerconfig does not exist")
 	}
 
+	setDockerImageOptions()
+}
+
+// Options needed to name the app image, without touching docker
+func setDockerImageOptions() {
+	dockerOptions.AppDir = defaultOptions.AppDir
+
 	if helpers.IsBlank(dockerOptions.Repository) && dockerOptions.Registry == docker.DOCKERHUB {
 		if helpers.IsBlank(dockerOptions.Username) {
 			panic("docker.username is required")
@@ -268,6 +630,235 @@ func setDockerOptions() {
 }
 
 func setKubeOptions() {
+	setNamespaceOptions()
+
+	kubeOptions.deploymentOptions.EnvVars = kube.SplitEnvVars(kubeOptions.deploymentOptions.EnvVars)
+
+	if helpers.IsBlank(kubeOptions.ingressOptions.Host) {
+		kubeOptions.ingressOptions.Host = fmt.Sprintf("%s.com", defaultOptions.AppName)
+	}
+
+	if kubeOptions.ingressOptions.TLS && !helpers.IsBlank(kubeOptions.ingressOptions.Issuer) {
+		if kubeOptions.ingressOptions.SelfSigned {
+			panic("kube.ingress.issuer and kube.ingress.selfsigned cannot be used together")
+		}
+		kubeOptions.ingressOptions.IssuerKind = strings.ToLower(kubeOptions.ingressOptions.IssuerKind)
+		if kubeOptions.ingressOptions.IssuerKind != kube.IssuerKindClusterIssuer && kubeOptions.ingressOptions.IssuerKind != kube.IssuerKindIssuer {
+			panic(fmt.Sprintf("unsupported issuer kind: %s", kubeOptions.ingressOptions.IssuerKind))
+		}
+		if kubeOptions.ingressOptions.IssuerTimeout <= 0 {
+			panic("kube.ingress.issuertimeout must be positive")
+		}
+	} else if kubeOptions.ingressOptions.TLS && kubeOptions.ingressOptions.SelfSigned {
+		checkKeyType(kubeOptions.ingressOptions.SelfSignedKeyType)
+	} else if kubeOptions.ingressOptions.TLS {
+		if helpers.IsBlank(kubeOptions.ingressOptions.CrtPath) {
+			panic("crt path does not exist")
+		}
+		if helpers.IsBlank(kubeOptions.ingressOptions.KeyPath) {
+			panic("key path does not exist")
+		}
+	}
+
+	kubeOptions.Workload = strings.ToLower(kubeOptions.Workload)
+	switch kubeOptions.Workload {
+	case kube.WorkloadDeployment:
+	case kube.WorkloadStatefulSet, kube.WorkloadJob, kube.WorkloadCronJob:
+		if strings.ToLower(kubeOptions.deploymentOptions.Strategy) != kube.StrategyRollingUpdate {
+			panic(fmt.Sprintf("%s workload only supports rollingupdate strategy", kubeOptions.Workload))
+		}
+		if kubeOptions.Workload == kube.WorkloadCronJob && helpers.IsBlank(kubeOptions.cronJobOptions.Schedule) {
+			panic("kube.cronjob.schedule is required for cronjob workload")
+		}
+	case kube.WorkloadDaemonSet:
+		if strings.ToLower(kubeOptions.deploymentOptions.Strategy) != kube.StrategyRollingUpdate {
+			panic("daemonset workload only supports rollingupdate strategy")
+		}
+		if kubeOptions.deploymentOptions.VolumeMount.Enabled {
+			panic("daemonset workload does not support pvc, use kube.daemonset.hostpaths instead")
+		}
+		// One pod runs on each node, so there is nothing to autoscale
+		kubeOptions.hpaOptions.Enabled = false
+	default:
+		panic(fmt.Sprintf("unsupported workload: %s", kubeOptions.Workload))
+	}
+
+	kubeOptions.deploymentOptions.Strategy = strings.ToLower(kubeOptions.deploymentOptions.Strategy)
+	switch kubeOptions.deploymentOptions.Strategy {
+	case kube.StrategyRollingUpdate:
+	case kube.StrategyBlueGreen:
+		if kubeOptions.RolloutTimeout <= 0 {
+			panic("kube.rollout.timeout must be positive for blue/green strategy")
+		}
+	case kube.StrategyCanary:
+		if kubeOptions.RolloutTimeout <= 0 {
+			panic("kube.rollout.timeout must be positive for canary strategy")
+		}
+		if err := kube.CheckCanarySteps(kubeOptions.deploymentOptions.Canary.Steps); err != nil {
+			panic(fmt.Errorf("invalid kube.deployment.canary.steps: %v", err))
+		}
+	default:
+		panic(fmt.Sprintf("unsupported deployment strategy: %s", kubeOptions.deploymentOptions.Strategy))
+	}
+
+	setServiceOptions()
+	setIngressOptions()
+	setResourceOptions()
+	setHPAOptions()
+	setPDBOptions()
+	setNetworkPolicyOptions()
+	setRBACOptions()
+}
+
+// Validate the rbac rules up front, they are read from config each time they are needed
+func setRBACOptions() {
+	rbacOptions := rbacOptions()
+	if len(rbacOptions.Rules) == 0 {
+		return
+	}
+	if !kubeOptions.AutomountToken {
+		panic("rbac rules need kube.serviceaccount.automounttoken for the app to authenticate with its serviceaccount")
+	}
+	if _, err := kube.NewRole(rbacOptions); err != nil {
+		panic(err)
+	}
+}
+
+// Validate service options and parse the extra service ports
+func setServiceOptions() {
+	serviceOptions := &kubeOptions.serviceOptions
+
+	serviceOptions.Type = strings.ToLower(serviceOptions.Type)
+	switch serviceOptions.Type {
+	case kube.ServiceTypeClusterIP, kube.ServiceTypeHeadless:
+		if serviceOptions.NodePort > 0 {
+			panic(fmt.Sprintf("kube.service.nodeport is not supported by %s service", serviceOptions.Type))
+		}
+	case kube.ServiceTypeNodePort, kube.ServiceTypeLoadBalancer:
+	default:
+		panic(fmt.Sprintf("unsupported service type: %s", serviceOptions.Type))
+	}
+
+	switch {
+	case strings.EqualFold(serviceOptions.SessionAffinity, "none"):
+		serviceOptions.SessionAffinity = "None"
+	case strings.EqualFold(serviceOptions.SessionAffinity, "clientip"):
+		serviceOptions.SessionAffinity = "ClientIP"
+	default:
+		panic(fmt.Sprintf("unsupported service session affinity: %s", serviceOptions.SessionAffinity))
+	}
+
+	switch {
+	case strings.EqualFold(serviceOptions.ExternalTrafficPolicy, "cluster"):
+		serviceOptions.ExternalTrafficPolicy = "Cluster"
+	case strings.EqualFold(serviceOptions.ExternalTrafficPolicy, "local"):
+		serviceOptions.ExternalTrafficPolicy = "Local"
+	default:
+		panic(fmt.Sprintf("unsupported service external traffic policy: %s", serviceOptions.ExternalTrafficPolicy))
+	}
+
+	names := map[string]bool{"http": true}
+	containerPorts := map[int32]bool{kubeOptions.deploymentOptions.Port: true}
+	serviceOptions.ExtraPorts = nil
+	kubeOptions.deploymentOptions.ExtraPorts = nil
+	for _, input := range kubeOptions.ServicePorts {
+		port, err := kube.ParseServicePort(input)
+		if err != nil {
+			panic(err)
+		}
+		if names[port.Name] {
+			panic(fmt.Sprintf("duplicate service port name: %s", port.Name))
+		}
+		names[port.Name] = true
+		if port.NodePort > 0 && serviceOptions.Type != kube.ServiceTypeNodePort && serviceOptions.Type != kube.ServiceTypeLoadBalancer {
+			panic(fmt.Sprintf("node port of service port %s is not supported by %s service", port.Name, serviceOptions.Type))
+		}
+		serviceOptions.ExtraPorts = append(serviceOptions.ExtraPorts, port)
+
+		// Ports sharing a target port are declared on the app container only once
+		if !containerPorts[port.TargetPort] {
+			containerPorts[port.TargetPort] = true
+			kubeOptions.deploymentOptions.ExtraPorts = append(kubeOptions.deploymentOptions.ExtraPorts, kube.ContainerPort{
+				Name: port.Name,
+				Port: port.TargetPort,
+			})
+		}
+	}
+}
+
+// Parse ingress rules and annotations, and check the ports they route to
+func setIngressOptions() {
+	ingressOptions := &kubeOptions.ingressOptions
+
+	kubeOptions.Routing = strings.ToLower(kubeOptions.Routing)
+	switch kubeOptions.Routing {
+	case kube.RoutingIngress:
+		if kubeOptions.deploymentOptions.Strategy == kube.StrategyCanary && ingressOptions.ClassName != kube.IngressClassNginx {
+			panic("canary strategy requires nginx ingress class")
+		}
+	case kube.RoutingGateway:
+		if helpers.IsBlank(kubeOptions.gatewayOptions.Gateway) {
+			panic("kube.gateway.name is required for gateway routing")
+		}
+		if len(kubeOptions.GatewayBackends) > 0 && kubeOptions.deploymentOptions.Strategy == kube.StrategyCanary {
+			panic("kube.gateway.backends cannot be used with canary strategy, which sets the backends itself")
+		}
+		backends, err := kube.ParseRouteBackends(kubeOptions.GatewayBackends)
+		if err != nil {
+			panic(err)
+		}
+		kubeOptions.gatewayOptions.Backends = backends
+	default:
+		panic(fmt.Sprintf("unsupported routing: %s", kubeOptions.Routing))
+	}
+
+	ingressOptions.Annotations = map[string]string{}
+	for _, annotation := range kubeOptions.IngressAnnotations {
+		key, value, found := strings.Cut(annotation, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			panic(fmt.Sprintf("invalid format for ingress annotation, expected 'key=value', got '%s'", annotation))
+		}
+		ingressOptions.Annotations[key] = strings.TrimSpace(value)
+	}
+
+	rules, err := kube.ParseIngressRules(kubeOptions.IngressRules, ingressOptions.Host)
+	if err != nil {
+		panic(err)
+	}
+	ingressOptions.Rules = rules
+
+	ports := map[string]bool{"http": true}
+	for _, port := range kubeOptions.serviceOptions.ExtraPorts {
+		ports[port.Name] = true
+	}
+	if !ports[ingressOptions.ServicePort] {
+		panic(fmt.Sprintf("kube.ingress.serviceport %s is not a port of app service", ingressOptions.ServicePort))
+	}
+	for _, rule := range rules {
+		for _, path := range rule.Paths {
+			if path.ServicePort != "" && !ports[path.ServicePort] {
+				panic(fmt.Sprintf("service port %s of ingress path %s%s is not a port of app service", path.ServicePort, rule.Host, path.Path))
+			}
+		}
+	}
+
+	// Check the certificate files against the hosts before anything is applied
+	if ingressOptions.TLS && !useIssuer() && !ingressOptions.SelfSigned {
+		if _, _, err := kube.LoadTLSFiles(*ingressOptions); err != nil {
+			panic(err)
+		}
+	}
+
+	// Hosts of an httproute share paths, and not every path type is supported
+	if kubeOptions.Routing == kube.RoutingGateway {
+		if _, err := kube.NewHTTPRoute(gatewayOptions()); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func setKubeconfigOptions() {
 	kubeOptions.Kubeconfig = helpers.ExpandUser(kubeOptions.Kubeconfig)
 	exist, err := helpers.IsFileExist(kubeOptions.Kubeconfig)
 	if err != nil {
@@ -277,20 +868,461 @@ func setKubeOptions() {
 		panic("kubeconfig does not exist")
 	}
 
+	setNamespaceOptions()
+}
+
+func setNamespaceOptions() {
 	if helpers.IsBlank(kubeOptions.Namespace) {
 		kubeOptions.Namespace = defaultOptions.AppName
 	}
+}
 
-	if helpers.IsBlank(kubeOptions.ingressOptions.Host) {
-		kubeOptions.ingressOptions.Host = fmt.Sprintf("%s.com", defaultOptions.AppName)
+// Fill in names, namespaces and image of kube resources
+func setResourceOptions() {
+	name := defaultOptions.AppName
+	namespace := kubeOptions.Namespace
+
+	kubeOptions.pvcOptions.Name = name
+	kubeOptions.pvcOptions.Namespace = namespace
+
+	kubeOptions.deploymentOptions.Name = name
+	kubeOptions.deploymentOptions.Namespace = namespace
+	kubeOptions.deploymentOptions.Image = dockerOptions.Image()
+	kubeOptions.deploymentOptions.HPAEnabled = kubeOptions.hpaOptions.Enabled
+	kubeOptions.deploymentOptions.DisableAutomountToken = !kubeOptions.AutomountToken
+	kubeOptions.deploymentOptions.Sidecars = sidecarOptions()
+	kubeOptions.deploymentOptions.InitContainers = initContainerOptions()
+	kubeOptions.deploymentOptions.ConfigMap = kubeOptions.configOptions.ConfigMap
+	kubeOptions.deploymentOptions.Secret = kubeOptions.configOptions.Secret
+
+	kubeOptions.configOptions.Name = name
+	kubeOptions.configOptions.Namespace = namespace
+	kubeOptions.configOptions.AppDir = defaultOptions.AppDir
+
+	kubeOptions.serviceOptions.Name = name
+	kubeOptions.serviceOptions.Namespace = namespace
+	kubeOptions.serviceOptions.TargetPort = kubeOptions.deploymentOptions.Port
+
+	kubeOptions.ingressOptions.Name = name
+	kubeOptions.ingressOptions.Namespace = namespace
+
+	kubeOptions.hpaOptions.Name = name
+	kubeOptions.hpaOptions.Namespace = namespace
+	kubeOptions.hpaOptions.Workload = kubeOptions.Workload
+
+	kubeOptions.pdbOptions.Name = name
+	kubeOptions.pdbOptions.Namespace = namespace
+
+	kubeOptions.networkPolicyOptions.Name = name
+	kubeOptions.networkPolicyOptions.Namespace = namespace
+}
+
+// Parse custom metrics and scaling behavior of the hpa, and make sure utilization metrics have resource requests to compare with
+func setHPAOptions() {
+	hpaOptions := &kubeOptions.hpaOptions
+	if !hpaOptions.Enabled {
+		return
 	}
 
-	if kubeOptions.ingressOptions.TLS && !kubeOptions.ingressOptions.SelfSigned {
-		if helpers.IsBlank(kubeOptions.ingressOptions.CrtPath) {
-			panic("crt path does not exist")
+	if hpaOptions.MinReplicas < 1 || hpaOptions.MaxReplicas < hpaOptions.MinReplicas {
+		panic("kube.hpa.minreplicas must be at least 1 and not greater than kube.hpa.maxreplicas")
+	}
+	if hpaOptions.CPURate < 0 || hpaOptions.MemoryRate < 0 {
+		panic("kube.hpa.cpurate and kube.hpa.memoryrate cannot be negative")
+	}
+
+	hpaOptions.Metrics = nil
+	for _, input := range kubeOptions.HPAMetrics {
+		metric, err := kube.ParseHPAMetric(input)
+		if err != nil {
+			panic(err)
 		}
-		if helpers.IsBlank(kubeOptions.ingressOptions.KeyPath) {
-			panic("key path does not exist")
+		hpaOptions.Metrics = append(hpaOptions.Metrics, metric)
+	}
+	hpaOptions.ScaleUp = hpaScalingRules("kube.hpa.scaleup", kubeOptions.HPAScaleUp)
+	hpaOptions.ScaleDown = hpaScalingRules("kube.hpa.scaledown", kubeOptions.HPAScaleDown)
+
+	// Utilization is measured against the requests of every app container, a missing one leaves the hpa unable to scale
+	if helpers.IsBlank(hpaOptions.CPUValue) && hpaOptions.CPURate > 0 {
+		checkHPARequests("kube.hpa.cpurate", "cpurequest", func(quota kube.Quota) bool {
+			return !helpers.IsBlank(quota.CPURequest) || !helpers.IsBlank(quota.CPULimit)
+		})
+	}
+	if helpers.IsBlank(hpaOptions.MemoryValue) && hpaOptions.MemoryRate > 0 {
+		checkHPARequests("kube.hpa.memoryrate", "memrequest", func(quota kube.Quota) bool {
+			return !helpers.IsBlank(quota.MemRequest) || !helpers.IsBlank(quota.MemLimit)
+		})
+	}
+
+	if _, err := kube.NewHPA(*hpaOptions); err != nil {
+		panic(err)
+	}
+}
+
+// Check the pdb against the replicas the app runs with, which are managed by the hpa when it is enabled
+func setPDBOptions() {
+	pdbOptions := &kubeOptions.pdbOptions
+	if isBatchWorkload() {
+		pdbOptions.Enabled = false
+	}
+	if !pdbOptions.Enabled {
+		return
+	}
+
+	if !helpers.IsBlank(pdbOptions.MinAvailable) && !helpers.IsBlank(pdbOptions.MaxUnavailable) {
+		panic("kube.pdb.minavailable and kube.pdb.maxunavailable cannot be used together")
+	}
+	if helpers.IsBlank(pdbOptions.MinAvailable) && helpers.IsBlank(pdbOptions.MaxUnavailable) {
+		pdbOptions.MaxUnavailable = "1"
+	}
+	if _, err := kube.NewPDB(*pdbOptions); err != nil {
+		panic(err)
+	}
+
+	// A daemonset has no scale subresource, so the eviction api only understands an absolute minAvailable for its pods
+	if kubeOptions.Workload == kube.WorkloadDaemonSet {
+		if helpers.IsBlank(pdbOptions.MinAvailable) || strings.HasSuffix(pdbOptions.MinAvailable, "%") {
+			panic("daemonset workload only supports an integer kube.pdb.minavailable")
+		}
+		return
+	}
+
+	minReplicas, maxReplicas := kubeOptions.deploymentOptions.Replicas, kubeOptions.deploymentOptions.Replicas
+	if kubeOptions.hpaOptions.Enabled {
+		minReplicas, maxReplicas = kubeOptions.hpaOptions.MinReplicas, kubeOptions.hpaOptions.MaxReplicas
+	}
+	warnings, err := kube.CheckPDB(*pdbOptions, minReplicas, maxReplicas)
+	if err != nil {
+		panic(fmt.Errorf("kube.pdb does not fit the replicas of app: %v", err))
+	}
+	// Warnings go to stderr to keep rendered manifests valid
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: pdb %s\n", warning)
+	}
+}
+
+// Derive the ports of the network policies from the service and the ingress rules
+func setNetworkPolicyOptions() {
+	networkPolicyOptions := &kubeOptions.networkPolicyOptions
+	if isBatchWorkload() {
+		networkPolicyOptions.Enabled = false
+	}
+	if !networkPolicyOptions.Enabled {
+		return
+	}
+
+	networkPolicyOptions.Service = kubeOptions.serviceOptions
+	networkPolicyOptions.Ingress = kubeOptions.ingressOptions
+	if _, err := kube.NewNetworkPolicies(*networkPolicyOptions); err != nil {
+		panic(err)
+	}
+
+	// Traffic through node ports and load balancers keeps the client or node address, which only a cidr can allow
+	if kubeOptions.serviceOptions.Type == kube.ServiceTypeNodePort || kubeOptions.serviceOptions.Type == kube.ServiceTypeLoadBalancer {
+		if len(networkPolicyOptions.AllowCIDRs) == 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s service is unreachable from outside the cluster until kube.networkpolicy.allowcidrs is set\n", kubeOptions.serviceOptions.Type)
+		}
+	}
+}
+
+func hpaScalingRules(key string, flags hpaScalingFlags) *kube.HPAScalingRules {
+	if helpers.IsBlank(flags.StabilizationWindow) && len(flags.Policies) == 0 && helpers.IsBlank(flags.SelectPolicy) {
+		return nil
+	}
+
+	rules := &kube.HPAScalingRules{
+		SelectPolicy: strings.ToLower(flags.SelectPolicy),
+	}
+	switch rules.SelectPolicy {
+	case "", kube.HPASelectPolicyMax, kube.HPASelectPolicyMin, kube.HPASelectPolicyDisabled:
+	default:
+		panic(fmt.Sprintf("unsupported %s.selectpolicy: %s", key, flags.SelectPolicy))
+	}
+
+	if !helpers.IsBlank(flags.StabilizationWindow) {
+		window, err := time.ParseDuration(flags.StabilizationWindow)
+		if err != nil || window < 0 || window > time.Hour || window%time.Second != 0 {
+			panic(fmt.Sprintf("%s.stabilizationwindow must be whole seconds between 0s and 1h", key))
+		}
+		seconds := int32(window.Seconds())
+		rules.StabilizationWindowSeconds = &seconds
+	}
+
+	for _, input := range flags.Policies {
+		policy, err := kube.ParseHPAScalingPolicy(input)
+		if err != nil {
+			panic(err)
+		}
+		rules.Policies = append(rules.Policies, policy)
+	}
+	return rules
+}
+
+func checkHPARequests(rateKey, quotaKey string, hasRequest func(kube.Quota) bool) {
+	var missing []string
+	if !hasRequest(kubeOptions.deploymentOptions.Quota) {
+		missing = append(missing, "kube.deployment.quota."+quotaKey)
+	}
+	for _, sidecar := range kubeOptions.deploymentOptions.Sidecars {
+		if !hasRequest(sidecar.Quota) {
+			missing = append(missing, fmt.Sprintf("quota.%s of sidecar %s", quotaKey, sidecar.Name))
+		}
+	}
+	if len(missing) > 0 {
+		panic(fmt.Sprintf("%s is relative to resource requests, set %s or use an average value target instead", rateKey, strings.Join(missing, ", ")))
+	}
+}
+
+// Sidecars are declared in [kube.sidecar.<name>] sections of config.ini, with the same keys as the app container
+func sidecarOptions() []kube.Container {
+	var names []string
+	for name := range viper.GetStringMap("kube.sidecar") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sidecars []kube.Container
+	for _, name := range names {
+		key := fmt.Sprintf("kube.sidecar.%s.", name)
+		for _, probe := range []string{"livenessprobe", "readinessprobe"} {
+			viper.SetDefault(key+probe+".type", kube.ProbeTypeHTTPGet)
+			viper.SetDefault(key+probe+".path", "/")
+			viper.SetDefault(key+probe+".scheme", "http")
+		}
+
+		var ports []int32
+		for _, port := range getIntSlice(key + "ports") {
+			ports = append(ports, int32(port))
+		}
+
+		sidecars = append(sidecars, kube.Container{
+			Name:    name,
+			Image:   viper.GetString(key + "image"),
+			Ports:   ports,
+			EnvVars: getLines(key + "env"),
+			Quota: kube.Quota{
+				CPULimit:   viper.GetString(key + "quota.cpulimit"),
+				MemLimit:   viper.GetString(key + "quota.memlimit"),
+				CPURequest: viper.GetString(key + "quota.cpurequest"),
+				MemRequest: viper.GetString(key + "quota.memrequest"),
+			},
+			LivenessProbe: kube.LivenessProbe{
+				Enabled:     viper.GetBool(key + "livenessprobe.enabled"),
+				Type:        viper.GetString(key + "livenessprobe.type"),
+				Path:        viper.GetString(key + "livenessprobe.path"),
+				Scheme:      viper.GetString(key + "livenessprobe.scheme"),
+				Command:     viper.GetString(key + "livenessprobe.command"),
+				ProbeParams: probeParams(key + "livenessprobe."),
+			},
+			ReadinessProbe: kube.ReadinessProbe{
+				Enabled:     viper.GetBool(key + "readinessprobe.enabled"),
+				Type:        viper.GetString(key + "readinessprobe.type"),
+				Path:        viper.GetString(key + "readinessprobe.path"),
+				Scheme:      viper.GetString(key + "readinessprobe.scheme"),
+				Command:     viper.GetString(key + "readinessprobe.command"),
+				ProbeParams: probeParams(key + "readinessprobe."),
+			},
+			VolumeMounts: getStringSlice(key + "volumemounts"),
+		})
+	}
+	return sidecars
+}
+
+// Init containers are declared in [kube.initcontainer.<name>] sections of config.ini and run in the order of kube.deployment.initcontainers
+func initContainerOptions() []kube.InitContainer {
+	var containers []kube.InitContainer
+	for _, name := range kubeOptions.InitContainers {
+		key := fmt.Sprintf("kube.initcontainer.%s", strings.ToLower(name))
+		if !viper.IsSet(key) {
+			panic(fmt.Sprintf("init container %s is not declared in config", name))
+		}
+		containers = append(containers, kube.InitContainer{
+			Name:         strings.ToLower(name),
+			Image:        viper.GetString(key + ".image"),
+			Command:      viper.GetString(key + ".command"),
+			EnvVars:      getLines(key + ".env"),
+			VolumeMounts: getStringSlice(key + ".volumemounts"),
+		})
+	}
+	return containers
+}
+
+func probeParams(key string) kube.ProbeParams {
+	return kube.ProbeParams{
+		InitialDelaySeconds: viper.GetInt32(key + "initialdelayseconds"),
+		TimeoutSeconds:      viper.GetInt32(key + "timeoutseconds"),
+		PeriodSeconds:       viper.GetInt32(key + "periodseconds"),
+		SuccessThreshold:    viper.GetInt32(key + "successthreshold"),
+		FailureThreshold:    viper.GetInt32(key + "failurethreshold"),
+	}
+}
+
+// Point deployment, service and hpa at the given blue/green color
+func setColorOptions(color string) {
+	kubeOptions.deploymentOptions.Color = color
+	kubeOptions.serviceOptions.Color = color
+	kubeOptions.hpaOptions.Color = color
+}
+
+// The idle color may have been scaled down to zero after the last switch.
+// With hpa enabled its replicas are left out of the deployment, and the hpa never scales up from zero
+func scaleUpColor(clientset *kubernetes.Clientset, ctx context.Context, color string) {
+	replicas := kubeOptions.deploymentOptions.Replicas
+	if kubeOptions.hpaOptions.Enabled {
+		replicas = kubeOptions.hpaOptions.MinReplicas
+	}
+	if err := kube.ScaleUpColor(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, color, replicas); err != nil {
+		panic(err)
+	}
+}
+
+func rolloutOptions() kube.RolloutOptions {
+	return kube.RolloutOptions{
+		Name:      kube.DeploymentName(defaultOptions.AppName, kubeOptions.deploymentOptions.Color),
+		Namespace: kubeOptions.Namespace,
+		Timeout:   kubeOptions.RolloutTimeout,
+		Workload:  kubeOptions.Workload,
+	}
+}
+
+func statefulSetOptions() kube.StatefulSetOptions {
+	return kube.StatefulSetOptions{
+		Deployment: kubeOptions.deploymentOptions,
+		Service:    kubeOptions.serviceOptions,
+		PVC:        kubeOptions.pvcOptions,
+	}
+}
+
+func gatewayOptions() kube.GatewayOptions {
+	opts := kubeOptions.gatewayOptions
+	opts.Name = defaultOptions.AppName
+	opts.Namespace = kubeOptions.Namespace
+	opts.Ingress = kubeOptions.ingressOptions
+	opts.Service = kubeOptions.serviceOptions
+	return opts
+}
+
+func daemonSetOptions() kube.DaemonSetOptions {
+	opts := kubeOptions.daemonSetOptions
+	opts.Deployment = kubeOptions.deploymentOptions
+	return opts
+}
+
+func jobOptions() kube.JobOptions {
+	return kube.JobOptions{
+		Deployment:   kubeOptions.deploymentOptions,
+		BackoffLimit: kubeOptions.cronJobOptions.Job.BackoffLimit,
+	}
+}
+
+func cronJobOptions() kube.CronJobOptions {
+	opts := kubeOptions.cronJobOptions
+	opts.Job = jobOptions()
+	return opts
+}
+
+func dockerSecretOptions() kube.DockerSecretOptions {
+	return kube.DockerSecretOptions{
+		Name:          defaultOptions.AppName,
+		Namespace:     kubeOptions.Namespace,
+		DockerOptions: dockerOptions,
+	}
+}
+
+func serviceAccountOptions() kube.ServiceAccountOptions {
+	return kube.ServiceAccountOptions{
+		Name:                  defaultOptions.AppName,
+		Namespace:             kubeOptions.Namespace,
+		DisableAutomountToken: !kubeOptions.AutomountToken,
+	}
+}
+
+// RBAC rules are declared in [kube.rbac.rule.<name>] sections of config.ini and granted to the app serviceaccount
+func rbacOptions() kube.RBACOptions {
+	var names []string
+	for name := range viper.GetStringMap("kube.rbac.rule") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rules []kube.RBACRule
+	for _, name := range names {
+		key := fmt.Sprintf("kube.rbac.rule.%s.", name)
+		rules = append(rules, kube.RBACRule{
+			Name:            name,
+			APIGroups:       getStringSlice(key + "apigroups"),
+			Resources:       getStringSlice(key + "resources"),
+			Verbs:           getStringSlice(key + "verbs"),
+			ResourceNames:   getStringSlice(key + "resourcenames"),
+			NonResourceURLs: getStringSlice(key + "nonresourceurls"),
+		})
+	}
+
+	return kube.RBACOptions{
+		Name:         defaultOptions.AppName,
+		Namespace:    kubeOptions.Namespace,
+		ClusterScope: kubeOptions.RBACClusterScope,
+		Rules:        rules,
+	}
+}
+
+// Comma separated lists in config.ini are read as plain strings
+func getStringSlice(key string) []string {
+	var values []string
+	for _, field := range strings.Split(viper.GetString(key), ",") {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			values = append(values, field)
 		}
 	}
+	return values
+}
+
+// Values which may contain commas, such as env, are written one per line in a """ quoted value of config.ini
+func getLines(key string) []string {
+	var values []string
+	for _, line := range strings.Split(viper.GetString(key), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+func getIntSlice(key string) []int {
+	var values []int
+	for _, field := range getStringSlice(key) {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			panic(fmt.Sprintf("%s must be a comma separated list of integers", key))
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func newRestConfig() *rest.Config {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeOptions.Kubeconfig)
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+func newClientset() *kubernetes.Clientset {
+	clientset, err := kubernetes.NewForConfig(newRestConfig())
+	if err != nil {
+		panic(err)
+	}
+	return clientset
+}
+
+// Dynamic client for resources without typed clients, such as custom resources
+func newDynamicClient() dynamic.Interface {
+	dynamicClient, err := dynamic.NewForConfig(newRestConfig())
+	if err != nil {
+		panic(err)
+	}
+	return dynamicClient
 }
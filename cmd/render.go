@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+	"github.com/guobinqiu/appdeployer/kube"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type RenderOptions struct {
+	OutputDir string
+	Secrets   bool
+}
+
+var renderOptions RenderOptions
+
+func init() {
+	renderCmd.Flags().StringVarP(&renderOptions.OutputDir, "output-dir", "o", "", "Directory to write one YAML file per resource into. Defaults to stdout")
+	renderCmd.Flags().BoolVar(&renderOptions.Secrets, "secrets", false, "Include docker, TLS and app secrets, which contain credentials, in the output")
+
+	kubeCmd.AddCommand(renderCmd)
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render kubernetes manifests of app as YAML without applying them",
+	Run: func(cmd *cobra.Command, args []string) {
+		setDefaultOptions()
+		if renderOptions.Secrets {
+			setDockerOptions()
+		} else {
+			setDockerImageOptions()
+		}
+		setKubeOptions()
+
+		// Without a cluster the live color is unknown, so blue/green is rendered as blue
+		if kubeOptions.deploymentOptions.Strategy == kube.StrategyBlueGreen {
+			setColorOptions(kube.ColorBlue)
+		}
+
+		objs, err := buildObjects(renderOptions.Secrets)
+		if err != nil {
+			panic(err)
+		}
+
+		if helpers.IsBlank(renderOptions.OutputDir) {
+			if err := kube.RenderYAML(os.Stdout, objs); err != nil {
+				panic(err)
+			}
+			return
+		}
+
+		if err := kube.RenderYAMLFiles(helpers.ExpandUser(renderOptions.OutputDir), objs); err != nil {
+			panic(err)
+		}
+	},
+}
+
+// Build the objects the kube command applies, in the same order
+func buildObjects(withSecrets bool) ([]runtime.Object, error) {
+	objs := []runtime.Object{
+		kube.NewNamespace(kubeOptions.Namespace),
+	}
+
+	if withSecrets {
+		dockerSecret, err := kube.NewDockerSecret(dockerSecretOptions())
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, dockerSecret)
+	}
+
+	objs = append(objs, kube.NewServiceAccount(serviceAccountOptions()))
+
+	if rbacOptions := rbacOptions(); len(rbacOptions.Rules) > 0 {
+		if rbacOptions.ClusterScope {
+			clusterRole, err := kube.NewClusterRole(rbacOptions)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, clusterRole, kube.NewClusterRoleBinding(rbacOptions))
+		} else {
+			role, err := kube.NewRole(rbacOptions)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, role, kube.NewRoleBinding(rbacOptions))
+		}
+	}
+
+	// Secrets are always read for the config hash, even when they are left out
+	configMaps, err := kube.NewConfigMaps(kubeOptions.configOptions)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := kube.NewSecrets(kubeOptions.configOptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, configMap := range configMaps {
+		objs = append(objs, configMap)
+	}
+	if withSecrets {
+		for _, secret := range secrets {
+			objs = append(objs, secret)
+		}
+	}
+	kubeOptions.deploymentOptions.ConfigHash = kube.ConfigHash(configMaps, secrets)
+
+	if isBatchWorkload() {
+		if kubeOptions.deploymentOptions.VolumeMount.Enabled {
+			objs = append(objs, kube.NewPVC(kubeOptions.pvcOptions))
+		}
+
+		if kubeOptions.Workload == kube.WorkloadJob {
+			job, err := kube.NewJob(jobOptions())
+			if err != nil {
+				return nil, err
+			}
+			return append(objs, job), nil
+		}
+		cronJob, err := kube.NewCronJob(cronJobOptions())
+		if err != nil {
+			return nil, err
+		}
+		return append(objs, cronJob), nil
+	}
+
+	if kubeOptions.Workload == kube.WorkloadStatefulSet {
+		statefulSet, err := kube.NewStatefulSet(statefulSetOptions())
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, kube.NewHeadlessService(kubeOptions.serviceOptions), statefulSet)
+	} else if kubeOptions.Workload == kube.WorkloadDaemonSet {
+		daemonSet, err := kube.NewDaemonSet(daemonSetOptions())
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, daemonSet)
+	} else {
+		if kubeOptions.deploymentOptions.VolumeMount.Enabled {
+			objs = append(objs, kube.NewPVC(kubeOptions.pvcOptions))
+		}
+
+		deployment, err := kube.NewDeployment(kubeOptions.deploymentOptions)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, deployment)
+	}
+	objs = append(objs, kube.NewService(kubeOptions.serviceOptions))
+
+	// The certificate holds no key, and cert-manager writes the tls secret
+	if useIssuer() {
+		objs = append(objs, kube.NewCertificate(kubeOptions.ingressOptions))
+	} else if kubeOptions.ingressOptions.TLS && withSecrets {
+		tlsSecret, err := kube.NewTlsSecret(kubeOptions.ingressOptions)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, tlsSecret)
+	}
+	if kubeOptions.Routing == kube.RoutingGateway {
+		gatewayOptions := gatewayOptions()
+		if kubeOptions.ingressOptions.TLS {
+			if grant := kube.NewReferenceGrant(gatewayOptions); grant != nil {
+				objs = append(objs, grant)
+			}
+			objs = append(objs, kube.NewHTTPRedirectRoute(gatewayOptions))
+		}
+		route, err := kube.NewHTTPRoute(gatewayOptions)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, route)
+	} else {
+		ingress, err := kube.NewIngress(kubeOptions.ingressOptions)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, ingress)
+	}
+
+	if kubeOptions.hpaOptions.Enabled {
+		hpa, err := kube.NewHPA(kubeOptions.hpaOptions)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, hpa)
+	}
+
+	if kubeOptions.pdbOptions.Enabled {
+		pdb, err := kube.NewPDB(kubeOptions.pdbOptions)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, pdb)
+	}
+
+	if kubeOptions.networkPolicyOptions.Enabled {
+		policies, err := kube.NewNetworkPolicies(kubeOptions.networkPolicyOptions)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range policies {
+			objs = append(objs, policy)
+		}
+	}
+
+	return objs, nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/guobinqiu/appdeployer/kube"
+	"github.com/spf13/cobra"
+)
+
+type RollbackOptions struct {
+	Revision int64
+	List     bool
+}
+
+var rollbackOptions RollbackOptions
+
+func init() {
+	rollbackCmd.Flags().Int64Var(&rollbackOptions.Revision, "revision", 0, "Revision to roll back to. Defaults to the previous revision")
+	rollbackCmd.Flags().BoolVar(&rollbackOptions.List, "list", false, "Only list revisions of app deployment without rolling back")
+
+	kubeCmd.AddCommand(rollbackCmd)
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back app deployment to a previous revision",
+	Run: func(cmd *cobra.Command, args []string) {
+		setDefaultOptions()
+		setKubeconfigOptions()
+
+		if !strings.EqualFold(kubeOptions.Workload, kube.WorkloadDeployment) {
+			panic("rollback only supports deployment workload")
+		}
+
+		clientset := newClientset()
+
+		//TODO handle timeout or cancel
+		ctx := context.TODO()
+
+		revisions, err := kube.ListRevisions(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace)
+		if err != nil {
+			panic(err)
+		}
+		printRevisions(revisions)
+
+		if rollbackOptions.List {
+			return
+		}
+
+		if err := kube.Rollback(clientset, ctx, kube.RollbackOptions{
+			Name:      defaultOptions.AppName,
+			Namespace: kubeOptions.Namespace,
+			Revision:  rollbackOptions.Revision,
+		}); err != nil {
+			panic(err)
+		}
+
+		if kubeOptions.RolloutTimeout > 0 {
+			if err := kube.WaitForRollout(clientset, ctx, rolloutOptions()); err != nil {
+				panic(err)
+			}
+		}
+	},
+}
+
+// Kubernetes does not record when a revision became current, so the creation time of its replicaset is shown instead
+func printRevisions(revisions []kube.Revision) {
+	redeployed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tIMAGE\tCREATED\t")
+	for _, revision := range revisions {
+		number := fmt.Sprint(revision.Number)
+		if revision.Current {
+			number += " (current)"
+		}
+		created := revision.CreatedAt.Local().Format(time.DateTime)
+		if revision.Redeployed {
+			created += " *"
+			redeployed = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", number, strings.Join(revision.Images, ","), created)
+	}
+	w.Flush()
+	if redeployed {
+		fmt.Println("* first deployed as an earlier revision, it became this revision later than the time shown")
+	}
+}
@@ -29,10 +29,16 @@ var rootCmd = &cobra.Command{
 	Long:  "App deployer is used to deploy your application to any kubernetes clusters as well as VMs via ansible",
 }
 
-func init() {
+// Package level variables are initialized before any init function, so the
+// flag defaults of every command (kube.go runs before root.go) see config.ini
+var _ = loadConfig()
+
+func loadConfig() error {
 	viper.SetConfigFile("./config.ini")
-	viper.ReadInConfig()
+	return viper.ReadInConfig()
+}
 
+func init() {
 	// default
 	rootCmd.PersistentFlags().StringVar(&defaultOptions.AppDir, "default.appdir", viper.GetString("default.appdir"), "App installation directory")
 	rootCmd.PersistentFlags().StringVar(&defaultOptions.AppName, "default.appname", viper.GetString("default.appname"), "Name of app. Defaults to name of app installation directory")
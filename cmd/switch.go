@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+	"github.com/guobinqiu/appdeployer/kube"
+	"github.com/spf13/cobra"
+)
+
+type SwitchOptions struct {
+	Color string
+}
+
+var switchOptions SwitchOptions
+
+func init() {
+	switchCmd.Flags().StringVar(&switchOptions.Color, "color", "", "Color to switch app service to. Such as blue and green. Defaults to the color not serving traffic")
+
+	kubeCmd.AddCommand(switchCmd)
+}
+
+var switchCmd = &cobra.Command{
+	Use:   "switch",
+	Short: "Switch app service between blue and green deployments",
+	Run: func(cmd *cobra.Command, args []string) {
+		setDefaultOptions()
+		setKubeconfigOptions()
+		setKubeOptions()
+
+		clientset := newClientset()
+
+		//TODO handle timeout or cancel
+		ctx := context.TODO()
+
+		liveColor, err := kube.LiveColor(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace)
+		if err != nil {
+			panic(err)
+		}
+		if liveColor == "" {
+			panic("app is not deployed with blue/green strategy")
+		}
+
+		color := kube.OtherColor(liveColor)
+		if !helpers.IsBlank(switchOptions.Color) {
+			color = switchOptions.Color
+		}
+		if color != kube.ColorBlue && color != kube.ColorGreen {
+			panic(fmt.Sprintf("unsupported color: %s", color))
+		}
+		if color == liveColor {
+			fmt.Printf("service %s already points to %s, no action taken\n", defaultOptions.AppName, color)
+			return
+		}
+		setColorOptions(color)
+
+		scaleUpColor(clientset, ctx, color)
+		if err := kube.WaitForRollout(clientset, ctx, rolloutOptions()); err != nil {
+			panic(err)
+		}
+		if err := kube.CheckColorAvailable(clientset, ctx, defaultOptions.AppName, kubeOptions.Namespace, color); err != nil {
+			panic(err)
+		}
+
+		if err := kube.CreateOrUpdateService(clientset, ctx, kubeOptions.serviceOptions); err != nil {
+			panic(err)
+		}
+
+		if kubeOptions.hpaOptions.Enabled {
+			if err := kube.CreateOrUpdateHPA(clientset, ctx, kubeOptions.hpaOptions); err != nil {
+				panic(err)
+			}
+		}
+	},
+}
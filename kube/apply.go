@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// 服务端应用（server-side apply）时使用的字段管理者名称
+const FieldManager = "appdeployer"
+
+// 所有类型化客户端（Deployments、Services、Ingresses 等）都实现了该方法
+type patcher[T any] interface {
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (T, error)
+}
+
+// 以服务端应用的方式创建或更新资源，只有 appdeployer 管理的字段会被覆盖
+func apply[T any](ctx context.Context, client patcher[T], obj runtime.Object, resourceName string) (T, error) {
+	var result T
+
+	if err := setTypeMeta(obj); err != nil {
+		return result, fmt.Errorf("failed to apply %s resource: %v", resourceName, err)
+	}
+
+	name, err := objectName(obj)
+	if err != nil {
+		return result, fmt.Errorf("failed to apply %s resource: %v", resourceName, err)
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return result, fmt.Errorf("failed to marshal %s resource: %v", resourceName, err)
+	}
+
+	force := true
+	result, err = client.Patch(ctx, name, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to apply %s resource: %v", resourceName, err)
+	}
+
+	fmt.Printf("%s resource %s successfully applied\n", resourceName, name)
+	return result, nil
+}
+
+// 服务端应用要求请求体中带有 apiVersion 和 kind
+func setTypeMeta(obj runtime.Object) error {
+	if !obj.GetObjectKind().GroupVersionKind().Empty() {
+		return nil
+	}
+
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return err
+	}
+	if len(gvks) == 0 {
+		return fmt.Errorf("unknown kind for %T", obj)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+	return nil
+}
+
+func objectName(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return accessor.GetName(), nil
+}
+
+// 所有类型化客户端都实现了该方法
+type deleter interface {
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+}
+
+// 删除资源，资源不存在时不做任何操作，quiet 时也不输出信息，用于清理切换选项后可能残留的资源
+func remove(ctx context.Context, client deleter, name, namespace, resourceName string, quiet bool) error {
+	location := name
+	if namespace != "" {
+		location = fmt.Sprintf("%s in namespace %s", name, namespace)
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	err := client.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete %s resource: %v", resourceName, err)
+	}
+	if apierrors.IsNotFound(err) {
+		if !quiet {
+			fmt.Printf("%s resource %s not found, no action taken\n", resourceName, location)
+		}
+	} else {
+		fmt.Printf("%s resource %s successfully deleted\n", resourceName, location)
+	}
+	return nil
+}
+
+// dynamic 客户端的 Delete 多了 subresources 参数，包装后才能用于 remove
+type dynamicResource struct {
+	client dynamic.ResourceInterface
+}
+
+func (r dynamicResource) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return r.client.Delete(ctx, name, opts)
+}
@@ -0,0 +1,149 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	StrategyRollingUpdate = "rollingupdate"
+	StrategyBlueGreen     = "bluegreen"
+
+	ColorBlue  = "blue"
+	ColorGreen = "green"
+
+	colorLabel = "color"
+)
+
+type BlueGreen struct {
+	ScaleDownDelay time.Duration // 切换后保留旧颜色的时长，期间可以立即切回
+}
+
+// 蓝绿发布时 Deployment 名为 <app>-<color>
+func DeploymentName(name, color string) string {
+	if color == "" {
+		return name
+	}
+	return name + "-" + color
+}
+
+func podLabels(name, color string) map[string]string {
+	labels := map[string]string{
+		"name": name,
+	}
+	if color != "" {
+		labels[colorLabel] = color
+	}
+	return labels
+}
+
+// Service 当前指向的颜色，尚未进行过蓝绿发布时返回空字符串
+func LiveColor(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string) (string, error) {
+	service, err := clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get service resource: %v", err)
+	}
+	return service.Spec.Selector[colorLabel], nil
+}
+
+func OtherColor(color string) string {
+	if color == ColorBlue {
+		return ColorGreen
+	}
+	return ColorBlue
+}
+
+// 将指定颜色的 Deployment 缩容到 0，保留其 pod 模板以便之后切回
+// 等待期间可能已经执行过 switch，所以缩容前重新确认该颜色没有在对外服务
+func ScaleDownColor(clientset *kubernetes.Clientset, ctx context.Context, name, namespace, color string) error {
+	liveColor, err := LiveColor(clientset, ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+	if liveColor == color {
+		fmt.Printf("service %s points to %s again, keeping deployment %s\n", name, color, DeploymentName(name, color))
+		return nil
+	}
+
+	deploymentName := DeploymentName(name, color)
+	scale, err := clientset.AppsV1().Deployments(namespace).GetScale(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get deployment scale: %v", err)
+	}
+	if scale.Spec.Replicas == 0 {
+		return nil
+	}
+
+	scale.Spec.Replicas = 0
+	if _, err := clientset.AppsV1().Deployments(namespace).UpdateScale(ctx, deploymentName, scale, metav1.UpdateOptions{
+		FieldManager: FieldManager,
+	}); err != nil {
+		return fmt.Errorf("failed to scale down deployment resource: %v", err)
+	}
+	fmt.Printf("deployment resource %s in namespace %s successfully scaled down\n", deploymentName, namespace)
+	return nil
+}
+
+// 将指定颜色的 Deployment 扩容到 replicas，已有副本时不做任何操作
+func ScaleUpColor(clientset *kubernetes.Clientset, ctx context.Context, name, namespace, color string, replicas int32) error {
+	deploymentName := DeploymentName(name, color)
+	scale, err := clientset.AppsV1().Deployments(namespace).GetScale(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment scale: %v", err)
+	}
+	if scale.Spec.Replicas > 0 {
+		return nil
+	}
+
+	scale.Spec.Replicas = replicas
+	if _, err := clientset.AppsV1().Deployments(namespace).UpdateScale(ctx, deploymentName, scale, metav1.UpdateOptions{
+		FieldManager: FieldManager,
+	}); err != nil {
+		return fmt.Errorf("failed to scale up deployment resource: %v", err)
+	}
+	fmt.Printf("deployment resource %s in namespace %s successfully scaled up to %d\n", deploymentName, namespace, replicas)
+	return nil
+}
+
+// 切换 Service 前确认该颜色有可用的副本，否则流量会全部转发到没有 pod 的 Deployment
+func CheckColorAvailable(clientset *kubernetes.Clientset, ctx context.Context, name, namespace, color string) error {
+	deploymentName := DeploymentName(name, color)
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment resource: %v", err)
+	}
+	if deployment.Status.AvailableReplicas == 0 {
+		return fmt.Errorf("deployment %s has no available replicas, service %s is not switched to %s", deploymentName, name, color)
+	}
+	return nil
+}
+
+// 删除 app 的蓝绿 Deployment，用于切回滚动更新或销毁 app
+func DeleteColorDeployments(clientset *kubernetes.Clientset, ctx context.Context, opts DeploymentOptions) error {
+	return deleteDeployments(clientset, ctx, opts.Namespace, DeploymentName(opts.Name, ColorBlue), DeploymentName(opts.Name, ColorGreen))
+}
+
+// 删除 app 不带颜色的 Deployment，用于从滚动更新切换到蓝绿发布
+func DeletePlainDeployment(clientset *kubernetes.Clientset, ctx context.Context, opts DeploymentOptions) error {
+	return deleteDeployments(clientset, ctx, opts.Namespace, opts.Name)
+}
+
+func deleteDeployments(clientset *kubernetes.Clientset, ctx context.Context, namespace string, names ...string) error {
+	for _, name := range names {
+		if err := remove(ctx, clientset.AppsV1().Deployments(namespace), name, namespace, "deployment", true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const StrategyCanary = "canary"
+
+const (
+	canaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	canaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+type Canary struct {
+	Steps []int         // 依次切给金丝雀的流量百分比，如 10,25,50,100
+	Pause time.Duration // 每一步之后的观察时间
+}
+
+type CanaryOptions struct {
+	Deployment DeploymentOptions
+	Service    ServiceOptions
+	Ingress    IngressOptions
+	Routing    string         // ingress 使用 nginx 的 canary 注解，gateway 使用 HTTPRoute 的 backend 权重
+	Gateway    GatewayOptions // Routing 为 gateway 时使用
+	Timeout    time.Duration
+}
+
+// 金丝雀的 Deployment、Service 和 Ingress 都命名为 <app>-canary
+func CanaryName(name string) string {
+	return name + "-canary"
+}
+
+// 金丝雀的流量百分比必须在 1 到 100 之间且逐步增大
+func CheckCanarySteps(steps []int) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("steps are required for canary strategy")
+	}
+	for i, weight := range steps {
+		if weight < 1 || weight > 100 {
+			return fmt.Errorf("step %d%% is not between 1%% and 100%%", weight)
+		}
+		if i > 0 && weight <= steps[i-1] {
+			return fmt.Errorf("step %d%% is not larger than the previous step %d%%", weight, steps[i-1])
+		}
+	}
+	return nil
+}
+
+// 部署金丝雀并按计划逐步调大流量权重，每一步之后检查金丝雀是否健康
+//
+// 成功后由调用方将新版本推广到主 Deployment 并调用 DeleteCanary；失败时金丝雀会被移除
+func DeployCanary(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, opts CanaryOptions) error {
+	name := CanaryName(opts.Deployment.Name)
+
+	deployment, err := NewCanaryDeployment(opts.Deployment)
+	if err != nil {
+		return err
+	}
+	if _, err := apply(ctx, clientset.AppsV1().Deployments(opts.Deployment.Namespace), deployment, "deployment"); err != nil {
+		return err
+	}
+
+	rollout := RolloutOptions{
+		Name:      name,
+		Namespace: opts.Deployment.Namespace,
+		Timeout:   opts.Timeout,
+	}
+	if err := WaitForRollout(clientset, ctx, rollout); err != nil {
+		return abortCanary(clientset, dynamicClient, ctx, opts, err)
+	}
+
+	if _, err := apply(ctx, clientset.CoreV1().Services(opts.Service.Namespace), NewCanaryService(opts.Service), "service"); err != nil {
+		return abortCanary(clientset, dynamicClient, ctx, opts, err)
+	}
+
+	for _, weight := range opts.Deployment.Canary.Steps {
+		if err := shiftCanaryTraffic(clientset, dynamicClient, ctx, opts, weight); err != nil {
+			return abortCanary(clientset, dynamicClient, ctx, opts, err)
+		}
+		fmt.Printf("canary %s receives %d%% of traffic, pausing for %s\n", name, weight, opts.Deployment.Canary.Pause)
+		time.Sleep(opts.Deployment.Canary.Pause)
+
+		if err := checkCanary(clientset, ctx, rollout); err != nil {
+			return abortCanary(clientset, dynamicClient, ctx, opts, err)
+		}
+	}
+
+	fmt.Printf("canary %s is healthy, promoting\n", name)
+	return nil
+}
+
+// 调整金丝雀的流量权重
+func shiftCanaryTraffic(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, opts CanaryOptions, weight int) error {
+	if opts.Routing == RoutingGateway {
+		route, err := NewCanaryHTTPRoute(opts.Gateway, weight)
+		if err != nil {
+			return err
+		}
+		_, err = apply(ctx, dynamicClient.Resource(httpRouteResource).Namespace(opts.Gateway.Namespace), route, "httproute")
+		return err
+	}
+
+	ingress, err := NewCanaryIngress(opts.Ingress, weight)
+	if err != nil {
+		return err
+	}
+	_, err = apply(ctx, clientset.NetworkingV1().Ingresses(opts.Ingress.Namespace), ingress, "ingress")
+	return err
+}
+
+func NewCanaryDeployment(opts DeploymentOptions) (*appsv1.Deployment, error) {
+	deployment, err := NewDeployment(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 金丝雀的 pod 不能被主 Service 选中
+	name := CanaryName(opts.Name)
+	labels := map[string]string{
+		"name": name,
+	}
+	deployment.Name = name
+	deployment.Spec.Selector.MatchLabels = labels
+	deployment.Spec.Template.Labels = labels
+	deployment.Spec.Replicas = &opts.Replicas
+	return deployment, nil
+}
+
+func NewCanaryService(opts ServiceOptions) *corev1.Service {
+	service := newClusterIPService(opts)
+	service.Name = CanaryName(opts.Name)
+	service.Spec.Selector = map[string]string{
+		"name": CanaryName(opts.Name),
+	}
+	return service
+}
+
+func NewCanaryIngress(opts IngressOptions, weight int) (*networkingv1.Ingress, error) {
+	ingress, err := NewIngress(opts)
+	if err != nil {
+		return nil, err
+	}
+	ingress.Name = CanaryName(opts.Name)
+	ingress.Annotations[canaryAnnotation] = "true"
+	ingress.Annotations[canaryWeightAnnotation] = strconv.Itoa(weight)
+	for _, rule := range ingress.Spec.Rules {
+		for i := range rule.HTTP.Paths {
+			rule.HTTP.Paths[i].Backend.Service.Name = CanaryName(opts.Name)
+		}
+	}
+	return ingress, nil
+}
+
+// 在 app 的 HTTPRoute 中按权重把流量分给金丝雀的 Service
+func NewCanaryHTTPRoute(opts GatewayOptions, weight int) (*unstructured.Unstructured, error) {
+	opts.Backends = []RouteBackend{
+		{Name: opts.Name, Weight: int32(100 - weight)},
+		{Name: CanaryName(opts.Name), Weight: int32(weight)},
+	}
+	return NewHTTPRoute(opts)
+}
+
+// 金丝雀的副本需要全部可用且没有容器重启
+func checkCanary(clientset *kubernetes.Clientset, ctx context.Context, opts RolloutOptions) error {
+	deployment, err := clientset.AppsV1().Deployments(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment resource: %v", err)
+	}
+	message, done, err := rolloutStatus(deployment)
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("canary is not ready: %s", message)
+	}
+
+	pods, err := clientset.CoreV1().Pods(opts.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.RestartCount > 0 {
+				return fmt.Errorf("container %s of canary pod %s restarted %d times", status.Name, pod.Name, status.RestartCount)
+			}
+		}
+	}
+	return nil
+}
+
+func abortCanary(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, opts CanaryOptions, cause error) error {
+	fmt.Printf("aborting canary: %v\n", cause)
+	// HTTPRoute 由 app 和金丝雀共用，删除金丝雀的 Service 之前先把流量全部切回 app
+	if opts.Routing == RoutingGateway {
+		if route, err := NewHTTPRoute(opts.Gateway); err == nil {
+			if _, err := apply(ctx, dynamicClient.Resource(httpRouteResource).Namespace(opts.Gateway.Namespace), route, "httproute"); err != nil {
+				return fmt.Errorf("canary aborted: %v; %v", cause, err)
+			}
+		}
+	}
+	printRolloutFailure(clientset, ctx, RolloutOptions{
+		Name:      CanaryName(opts.Deployment.Name),
+		Namespace: opts.Deployment.Namespace,
+	})
+	if err := DeleteCanary(clientset, ctx, opts.Deployment.Name, opts.Deployment.Namespace); err != nil {
+		return fmt.Errorf("canary aborted: %v; %v", cause, err)
+	}
+	return fmt.Errorf("canary aborted: %v", cause)
+}
+
+// 先移除流量入口再删除工作负载，资源不存在时不做任何操作
+func DeleteCanary(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string) error {
+	canaryName := CanaryName(name)
+
+	if err := remove(ctx, clientset.NetworkingV1().Ingresses(namespace), canaryName, namespace, "ingress", false); err != nil {
+		return err
+	}
+	if err := remove(ctx, clientset.CoreV1().Services(namespace), canaryName, namespace, "service", false); err != nil {
+		return err
+	}
+	return remove(ctx, clientset.AppsV1().Deployments(namespace), canaryName, namespace, "deployment", false)
+}
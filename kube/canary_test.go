@@ -0,0 +1,122 @@
+package kube
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCheckCanarySteps(t *testing.T) {
+	tests := []struct {
+		steps   []int
+		wantErr bool
+	}{
+		{steps: []int{10, 25, 50, 100}},
+		{steps: []int{100}},
+		{steps: []int{1, 99}},
+		{steps: nil, wantErr: true},
+		{steps: []int{0, 50}, wantErr: true},
+		{steps: []int{10, 101}, wantErr: true},
+		{steps: []int{-10, 50}, wantErr: true},
+		{steps: []int{50, 25}, wantErr: true},
+		{steps: []int{25, 25, 50}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.steps), func(t *testing.T) {
+			err := CheckCanarySteps(tt.steps)
+			if tt.wantErr && err == nil {
+				t.Errorf("CheckCanarySteps(%v) = nil, want error", tt.steps)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CheckCanarySteps(%v) error = %v", tt.steps, err)
+			}
+		})
+	}
+}
+
+func TestNewCanaryIngress(t *testing.T) {
+	opts := IngressOptions{
+		Name:      "hellogo",
+		Namespace: "hellogo",
+		Rules: []IngressRule{
+			{Host: "hellogo.com", Paths: []IngressPath{{Path: "/"}, {Path: "/api"}}},
+			{Host: "api.hellogo.com", Paths: []IngressPath{{Path: "/"}}},
+		},
+	}
+
+	for _, weight := range []int{10, 25, 50, 100} {
+		t.Run(strconv.Itoa(weight), func(t *testing.T) {
+			ingress, err := NewCanaryIngress(opts, weight)
+			if err != nil {
+				t.Fatalf("NewCanaryIngress() error = %v", err)
+			}
+			if ingress.Name != "hellogo-canary" {
+				t.Errorf("NewCanaryIngress() name = %s, want hellogo-canary", ingress.Name)
+			}
+			if got := ingress.Annotations[canaryAnnotation]; got != "true" {
+				t.Errorf("NewCanaryIngress() %s = %s, want true", canaryAnnotation, got)
+			}
+			if got := ingress.Annotations[canaryWeightAnnotation]; got != strconv.Itoa(weight) {
+				t.Errorf("NewCanaryIngress() %s = %s, want %d", canaryWeightAnnotation, got, weight)
+			}
+			for _, rule := range ingress.Spec.Rules {
+				for _, path := range rule.HTTP.Paths {
+					if path.Backend.Service.Name != "hellogo-canary" {
+						t.Errorf("NewCanaryIngress() backend of %s%s = %s, want hellogo-canary", rule.Host, path.Path, path.Backend.Service.Name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewCanaryHTTPRoute(t *testing.T) {
+	opts := GatewayOptions{
+		Name:      "hellogo",
+		Namespace: "hellogo",
+		Gateway:   "gateway",
+		Ingress:   IngressOptions{Host: "hellogo.com"},
+		Service:   ServiceOptions{Port: 80, TargetPort: 8080},
+	}
+
+	tests := []struct {
+		weight int
+		want   []interface{}
+	}{
+		{
+			weight: 10,
+			want: []interface{}{
+				map[string]interface{}{"name": "hellogo", "port": int64(80), "weight": int64(90)},
+				map[string]interface{}{"name": "hellogo-canary", "port": int64(80), "weight": int64(10)},
+			},
+		},
+		{
+			weight: 100,
+			want: []interface{}{
+				map[string]interface{}{"name": "hellogo", "port": int64(80), "weight": int64(0)},
+				map[string]interface{}{"name": "hellogo-canary", "port": int64(80), "weight": int64(100)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.weight), func(t *testing.T) {
+			route, err := NewCanaryHTTPRoute(opts, tt.weight)
+			if err != nil {
+				t.Fatalf("NewCanaryHTTPRoute() error = %v", err)
+			}
+			rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+			if len(rules) != 1 {
+				t.Fatalf("NewCanaryHTTPRoute() rules = %+v, want 1 rule", rules)
+			}
+			got := rules[0].(map[string]interface{})["backendRefs"]
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewCanaryHTTPRoute() backendRefs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
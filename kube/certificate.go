@@ -0,0 +1,355 @@
+package kube
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+)
+
+// 私钥类型，不区分大小写
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// CA 的默认名称和有效期
+const (
+	DefaultCACommonName = "appdeployer CA"
+	DefaultCAYears      = 10
+)
+
+const (
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+)
+
+// 签发自签名证书，CA 保存在 CADir 中供以后的发布复用
+type CertificateManager struct {
+	KeyType string // rsa（2048 位）、ecdsa（P-256）或 ed25519，默认为 rsa
+	CADir   string
+}
+
+// 生成私钥
+func (cm *CertificateManager) GenerateKey() (crypto.Signer, error) {
+	switch strings.ToLower(cm.KeyType) {
+	case "", KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type: '%s'", cm.KeyType)
+	}
+}
+
+// 创建一个CA
+func (cm *CertificateManager) CreateCACertificate(commonName string, years int) (*x509.Certificate, crypto.Signer, error) {
+	caPrivateKey, err := cm.GenerateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA private key: %v", err)
+	}
+
+	caCert, err := cm.signCACertificate(commonName, caPrivateKey, years)
+	if err != nil {
+		return nil, nil, err
+	}
+	return caCert, caPrivateKey, nil
+}
+
+// 使用原来的私钥和名称重新签发CA，旧CA签发的证书依然可以通过验证
+func (cm *CertificateManager) RenewCACertificate(caCert *x509.Certificate, caPrivateKey crypto.Signer, years int) (*x509.Certificate, error) {
+	return cm.signCACertificate(caCert.Subject.CommonName, caPrivateKey, years)
+}
+
+func (cm *CertificateManager) signCACertificate(commonName string, caPrivateKey crypto.Signer, years int) (*x509.Certificate, error) {
+	serialNumber, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置CA证书模板
+	now := time.Now()
+	caTemplate := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"appdeployer"},
+			CommonName:   commonName,
+		},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(years, 0, 0), // 有效期
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		MaxPathLen:            0,
+		MaxPathLenZero:        true,
+	}
+
+	// 根据模板创建自签名的CA证书
+	caBytes, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, caPrivateKey.Public(), caPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+
+	// 将CA证书解析为结构体
+	caCert, err := x509.ParseCertificate(caBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created CA certificate: %v", err)
+	}
+	return caCert, nil
+}
+
+// 使用CA签发服务器证书，hosts 中的 IP 地址作为 IP SAN，其余作为 DNS SAN
+func (cm *CertificateManager) CreateServerCertificate(caCert *x509.Certificate, caPrivateKey crypto.Signer, hosts []string, years int) ([]byte, crypto.Signer, error) {
+	// 生成服务器私钥
+	serverPrivateKey, err := cm.GenerateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate server private key: %v", err)
+	}
+
+	serverBytes, err := cm.signServerCertificate(caCert, caPrivateKey, serverPrivateKey, hosts, years)
+	if err != nil {
+		return nil, nil, err
+	}
+	return serverBytes, serverPrivateKey, nil
+}
+
+// 使用原来的私钥和 SAN 重新签发服务器证书
+func (cm *CertificateManager) RenewServerCertificate(caCert *x509.Certificate, caPrivateKey crypto.Signer, serverCert *x509.Certificate, serverPrivateKey crypto.Signer, years int) ([]byte, error) {
+	hosts := append([]string{}, serverCert.DNSNames...)
+	for _, ip := range serverCert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	return cm.signServerCertificate(caCert, caPrivateKey, serverPrivateKey, hosts, years)
+}
+
+func (cm *CertificateManager) signServerCertificate(caCert *x509.Certificate, caPrivateKey, serverPrivateKey crypto.Signer, hosts []string, years int) ([]byte, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("failed to create server certificate: no host given")
+	}
+
+	serialNumber, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	var dnsNames []string
+	var ipAddresses []net.IP
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			ipAddresses = append(ipAddresses, ip)
+		} else {
+			dnsNames = append(dnsNames, host)
+		}
+	}
+
+	// 证书不能比签发它的CA活得更久
+	now := time.Now()
+	notAfter := now.AddDate(years, 0, 0)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	// 只有 RSA 密钥用于密钥交换
+	keyUsage := x509.KeyUsageDigitalSignature
+	if _, ok := serverPrivateKey.(*rsa.PrivateKey); ok {
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	// 设置服务器证书模板
+	serverTemplate := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"appdeployer"},
+			CommonName:   hosts[0],
+		},
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+		NotBefore:   now,
+		NotAfter:    notAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:    keyUsage,
+	}
+
+	// 根据CA签发服务器证书
+	serverBytes, err := x509.CreateCertificate(rand.Reader, &serverTemplate, caCert, serverPrivateKey.Public(), caPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server certificate: %v", err)
+	}
+	return serverBytes, nil
+}
+
+// CA 已保存时读取，否则创建并保存
+func (cm *CertificateManager) LoadOrCreateCA(commonName string, years int) (*x509.Certificate, crypto.Signer, error) {
+	exist, err := helpers.IsFileExist(cm.caPath(caCertFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	if exist {
+		return cm.LoadCA()
+	}
+
+	caCert, caPrivateKey, err := cm.CreateCACertificate(commonName, years)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cm.SaveCA(caCert, caPrivateKey); err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(os.Stderr, "CA certificate saved to %s\n", cm.caPath(caCertFile))
+	return caCert, caPrivateKey, nil
+}
+
+// 从 CADir 读取CA证书和私钥
+func (cm *CertificateManager) LoadCA() (*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := os.ReadFile(cm.caPath(caCertFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	keyPEM, err := os.ReadFile(cm.caPath(caKeyFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA private key: %v", err)
+	}
+
+	caCert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	caPrivateKey, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !caCert.IsCA {
+		return nil, nil, fmt.Errorf("%s is not a CA certificate", cm.caPath(caCertFile))
+	}
+	return caCert, caPrivateKey, nil
+}
+
+// 将CA证书和私钥保存到 CADir，私钥只有当前用户可读
+func (cm *CertificateManager) SaveCA(caCert *x509.Certificate, caPrivateKey crypto.Signer) error {
+	keyPEM, err := cm.EncodePrivateKeyToPEM(caPrivateKey)
+	if err != nil {
+		return err
+	}
+	if err := helpers.WriteFile(cm.caPath(caKeyFile), keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to save CA private key: %v", err)
+	}
+	if err := helpers.WriteFile(cm.caPath(caCertFile), cm.EncodeCertificateToPEM(caCert.Raw), 0644); err != nil {
+		return fmt.Errorf("failed to save CA certificate: %v", err)
+	}
+	return nil
+}
+
+func (cm *CertificateManager) caPath(file string) string {
+	return filepath.Join(helpers.ExpandUser(cm.CADir), file)
+}
+
+// 将私钥转换为PEM格式（PKCS#8）
+func (cm *CertificateManager) EncodePrivateKeyToPEM(privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %v", err)
+	}
+	pemBlock := &pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: der,
+	}
+	return pem.EncodeToMemory(pemBlock), nil
+}
+
+// 将证书转换为PEM格式
+func (cm *CertificateManager) EncodeCertificateToPEM(cert []byte) []byte {
+	pemBlock := &pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: cert,
+	}
+	return pem.EncodeToMemory(pemBlock)
+}
+
+// 解析 PEM 中的所有证书
+func ParseCertificatesPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return certs, nil
+}
+
+// 解析 PEM 中的第一个证书
+func ParseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	certs, err := ParseCertificatesPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// 解析 PKCS#8、PKCS#1 或 EC 格式的私钥
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// 128 位随机序列号
+func randomSerialNumber() (*big.Int, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+	return serialNumber, nil
+}
@@ -0,0 +1,114 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// cert-manager 的 issuer 类型，不区分大小写
+const (
+	IssuerKindClusterIssuer = "clusterissuer"
+	IssuerKindIssuer        = "issuer"
+)
+
+const certManagerGroup = "cert-manager.io"
+
+var certificateResource = schema.GroupVersionResource{Group: certManagerGroup, Version: "v1", Resource: "certificates"}
+
+// 创建或更新 TLS 证书，配置了 issuer 时由 cert-manager 签发，否则自签名或读取证书文件
+func CreateOrUpdateTLS(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, opts IngressOptions) error {
+	if opts.Issuer == "" {
+		return CreateOrUpdateTlsSecret(clientset, ctx, opts)
+	}
+	return CreateOrUpdateCertificate(dynamicClient, ctx, opts)
+}
+
+// 创建或更新 cert-manager 的 Certificate，并等待证书签发完成
+func CreateOrUpdateCertificate(dynamicClient dynamic.Interface, ctx context.Context, opts IngressOptions) error {
+	certificates := dynamicClient.Resource(certificateResource).Namespace(opts.Namespace)
+	if _, err := apply(ctx, certificates, NewCertificate(opts), "certificate"); err != nil {
+		return err
+	}
+
+	fmt.Printf("waiting for certificate %s to be ready\n", opts.Name)
+	message := ""
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, opts.IssuerTimeout, true, func(ctx context.Context) (bool, error) {
+		certificate, err := certificates.Get(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get certificate resource: %v", err)
+		}
+		ready, reason := certificateReady(certificate)
+		if reason != message {
+			message = reason
+			fmt.Println(message)
+		}
+		return ready, nil
+	})
+	if err != nil {
+		return fmt.Errorf("certificate %s is not ready within %s: %v", opts.Name, opts.IssuerTimeout, err)
+	}
+	return nil
+}
+
+// 证书写入 tls-<app>，与自签名和证书文件使用同一个 secret，Ingress 和 Gateway 无需改动
+func NewCertificate(opts IngressOptions) *unstructured.Unstructured {
+	var dnsNames []interface{}
+	for _, host := range IngressHosts(opts) {
+		dnsNames = append(dnsNames, host)
+	}
+
+	kind := "ClusterIssuer"
+	if strings.ToLower(opts.IssuerKind) == IssuerKindIssuer {
+		kind = "Issuer"
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": certificateResource.GroupVersion().String(),
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"name":      opts.Name,
+				"namespace": opts.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"secretName": "tls-" + opts.Name,
+				"dnsNames":   dnsNames,
+				"issuerRef": map[string]interface{}{
+					"group": certManagerGroup,
+					"kind":  kind,
+					"name":  opts.Issuer,
+				},
+			},
+		},
+	}
+}
+
+// 返回 Ready 条件是否为 True，以及用于输出的原因
+func certificateReady(certificate *unstructured.Unstructured) (bool, string) {
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		status, _ := condition["status"].(string)
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		return status == "True", fmt.Sprintf("certificate %s: %s", reason, message)
+	}
+	return false, "waiting for certificate status to be reported..."
+}
+
+// 集群中没有 cert-manager 时同样视为不存在
+func DeleteCertificate(dynamicClient dynamic.Interface, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, dynamicResource{dynamicClient.Resource(certificateResource).Namespace(namespace)}, name, namespace, "certificate", quiet)
+}
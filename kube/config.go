@@ -0,0 +1,331 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// 配置内容的哈希写入 pod 模板的注解，配置变化时 pod 会随之重建
+const configHashAnnotation = "appdeployer/config-hash"
+
+const (
+	configVolumeName = "config"
+	secretVolumeName = "secret"
+)
+
+// app 的 ConfigMap 和 Secret，由 appdir 中的 env 文件和普通文件生成
+type ConfigOptions struct {
+	Name      string
+	Namespace string
+	AppDir    string
+	ConfigMap ConfigSource
+	Secret    ConfigSource
+}
+
+type ConfigSource struct {
+	EnvFile   string   // 相对于 appdir，每行一个 KEY=VALUE，通过 envFrom 注入
+	Files     []string // 相对于 appdir，以文件名为 key 挂载到 MountPath
+	MountPath string
+}
+
+// env 文件和普通文件分别生成各自的 ConfigMap/Secret，避免文件名被 envFrom 当作环境变量注入
+func envConfigName(name string) string {
+	return name + "-config-env"
+}
+
+func filesConfigName(name string) string {
+	return name + "-config-files"
+}
+
+func envSecretName(name string) string {
+	return name + "-secret-env"
+}
+
+func filesSecretName(name string) string {
+	return name + "-secret-files"
+}
+
+// 创建或更新 ConfigMap 和 Secret，返回配置内容的哈希
+func CreateOrUpdateConfig(clientset *kubernetes.Clientset, ctx context.Context, opts ConfigOptions) (string, error) {
+	configMaps, err := NewConfigMaps(opts)
+	if err != nil {
+		return "", err
+	}
+	secrets, err := NewSecrets(opts)
+	if err != nil {
+		return "", err
+	}
+
+	for _, configMap := range configMaps {
+		if _, err := apply(ctx, clientset.CoreV1().ConfigMaps(opts.Namespace), configMap, "configmap"); err != nil {
+			return "", err
+		}
+	}
+	for _, secret := range secrets {
+		if _, err := apply(ctx, clientset.CoreV1().Secrets(opts.Namespace), secret, "secret"); err != nil {
+			return "", err
+		}
+	}
+
+	return ConfigHash(configMaps, secrets), nil
+}
+
+func NewConfigMaps(opts ConfigOptions) ([]*corev1.ConfigMap, error) {
+	var configMaps []*corev1.ConfigMap
+
+	if !helpers.IsBlank(opts.ConfigMap.EnvFile) {
+		env, err := readEnvFile(filepath.Join(opts.AppDir, opts.ConfigMap.EnvFile))
+		if err != nil {
+			return nil, err
+		}
+		configMaps = append(configMaps, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      envConfigName(opts.Name),
+				Namespace: opts.Namespace,
+			},
+			Data: env,
+		})
+	}
+
+	if len(opts.ConfigMap.Files) > 0 {
+		files, err := readFiles(opts.AppDir, opts.ConfigMap.Files)
+		if err != nil {
+			return nil, err
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      filesConfigName(opts.Name),
+				Namespace: opts.Namespace,
+			},
+		}
+		// 非 UTF-8 的文件只能放在 BinaryData 中
+		for key, value := range files {
+			if utf8.Valid(value) {
+				if configMap.Data == nil {
+					configMap.Data = map[string]string{}
+				}
+				configMap.Data[key] = string(value)
+			} else {
+				if configMap.BinaryData == nil {
+					configMap.BinaryData = map[string][]byte{}
+				}
+				configMap.BinaryData[key] = value
+			}
+		}
+		configMaps = append(configMaps, configMap)
+	}
+
+	return configMaps, nil
+}
+
+func NewSecrets(opts ConfigOptions) ([]*corev1.Secret, error) {
+	var secrets []*corev1.Secret
+
+	if !helpers.IsBlank(opts.Secret.EnvFile) {
+		env, err := readEnvFile(filepath.Join(opts.AppDir, opts.Secret.EnvFile))
+		if err != nil {
+			return nil, err
+		}
+		data := map[string][]byte{}
+		for key, value := range env {
+			data[key] = []byte(value)
+		}
+		secrets = append(secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      envSecretName(opts.Name),
+				Namespace: opts.Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		})
+	}
+
+	if len(opts.Secret.Files) > 0 {
+		files, err := readFiles(opts.AppDir, opts.Secret.Files)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      filesSecretName(opts.Name),
+				Namespace: opts.Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: files,
+		})
+	}
+
+	return secrets, nil
+}
+
+// 按名称和 key 排序后计算 sha256，结果与 map 的遍历顺序无关
+func ConfigHash(configMaps []*corev1.ConfigMap, secrets []*corev1.Secret) string {
+	if len(configMaps) == 0 && len(secrets) == 0 {
+		return ""
+	}
+
+	hash := sha256.New()
+	write := func(kind, name string, data map[string][]byte) {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(hash, "%s/%s\n", kind, name)
+		for _, key := range keys {
+			fmt.Fprintf(hash, "%s=%d:", key, len(data[key]))
+			hash.Write(data[key])
+		}
+	}
+
+	for _, configMap := range configMaps {
+		data := map[string][]byte{}
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+		}
+		write("configmap", configMap.Name, data)
+	}
+	for _, secret := range secrets {
+		write("secret", secret.Name, secret.Data)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+func DeleteConfig(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string) error {
+	for _, configMapName := range []string{envConfigName(name), filesConfigName(name)} {
+		if err := remove(ctx, clientset.CoreV1().ConfigMaps(namespace), configMapName, namespace, "configmap", false); err != nil {
+			return err
+		}
+	}
+	for _, secretName := range []string{envSecretName(name), filesSecretName(name)} {
+		if err := remove(ctx, clientset.CoreV1().Secrets(namespace), secretName, namespace, "secret", false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 将 ConfigMap 和 Secret 注入 app 容器，挂载的卷同时登记到 volumes 中供 sidecar 使用
+func setConfig(podSpec *corev1.PodSpec, container *corev1.Container, opts DeploymentOptions, volumes map[string]bool) {
+	if !helpers.IsBlank(opts.ConfigMap.EnvFile) {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: envConfigName(opts.Name),
+				},
+			},
+		})
+	}
+	if !helpers.IsBlank(opts.Secret.EnvFile) {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: envSecretName(opts.Name),
+				},
+			},
+		})
+	}
+
+	if len(opts.ConfigMap.Files) > 0 {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: filesConfigName(opts.Name),
+					},
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      configVolumeName,
+			MountPath: opts.ConfigMap.MountPath,
+			ReadOnly:  true,
+		})
+		volumes[configVolumeName] = true
+	}
+	if len(opts.Secret.Files) > 0 {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: secretVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: filesSecretName(opts.Name),
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      secretVolumeName,
+			MountPath: opts.Secret.MountPath,
+			ReadOnly:  true,
+		})
+		volumes[secretVolumeName] = true
+	}
+}
+
+// 解析 env 文件，忽略空行和 # 开头的注释，值两端的引号会被去掉
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %v", err)
+	}
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid line %d in env file %s, expected 'KEY=VALUE'", lineNo, path)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %v", err)
+	}
+	return env, nil
+}
+
+// 以文件名作为 key 读取文件内容
+func readFiles(appDir string, paths []string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, path := range paths {
+		key := filepath.Base(path)
+		if _, ok := files[key]; ok {
+			return nil, fmt.Errorf("duplicate file name '%s'", key)
+		}
+		data, err := os.ReadFile(filepath.Join(appDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+		files[key] = data
+	}
+	return files, nil
+}
@@ -0,0 +1,117 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+const WorkloadDaemonSet = "daemonset"
+
+type DaemonSetOptions struct {
+	Deployment     DeploymentOptions // 复用 pod 模板相关的选项
+	MaxUnavailable string
+	HostNetwork    bool
+	HostPaths      []string // hostPath:mountPath[:ro]，挂载节点上的目录或文件
+}
+
+func CreateOrUpdateDaemonSet(clientset *kubernetes.Clientset, ctx context.Context, opts DaemonSetOptions) error {
+	daemonSet, err := NewDaemonSet(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = apply(ctx, clientset.AppsV1().DaemonSets(opts.Deployment.Namespace), daemonSet, "daemonset")
+	return err
+}
+
+func NewDaemonSet(opts DaemonSetOptions) (*appsv1.DaemonSet, error) {
+	template, err := NewPodTemplate(opts.Deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用宿主机网络时仍然需要解析集群内的域名
+	if opts.HostNetwork {
+		template.Spec.HostNetwork = true
+		template.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+	}
+
+	for i, hostPath := range opts.HostPaths {
+		volume, volumeMount, err := parseHostPath(fmt.Sprintf("host-%d", i), hostPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set host path: %v", err)
+		}
+		template.Spec.Volumes = append(template.Spec.Volumes, volume)
+		template.Spec.Containers[0].VolumeMounts = append(template.Spec.Containers[0].VolumeMounts, volumeMount)
+	}
+
+	maxUnavailable := intstr.Parse(opts.MaxUnavailable)
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Deployment.Name,
+			Namespace: opts.Deployment.Namespace,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: podLabels(opts.Deployment.Name, ""),
+			},
+			Template: template,
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{
+					MaxUnavailable: &maxUnavailable,
+				},
+			},
+		},
+	}, nil
+}
+
+// 解析 hostPath:mountPath[:ro]
+func parseHostPath(name, input string) (corev1.Volume, corev1.VolumeMount, error) {
+	parts := strings.Split(input, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] != "ro") {
+		return corev1.Volume{}, corev1.VolumeMount{}, fmt.Errorf("invalid format for host path, expected 'hostPath:mountPath[:ro]', got '%s'", input)
+	}
+
+	volume := corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: parts[0],
+			},
+		},
+	}
+	volumeMount := corev1.VolumeMount{
+		Name:      name,
+		MountPath: parts[1],
+		ReadOnly:  len(parts) == 3,
+	}
+	return volume, volumeMount, nil
+}
+
+func DeleteDaemonSet(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.AppsV1().DaemonSets(namespace), name, namespace, "daemonset", quiet)
+}
+
+// 参照 kubectl rollout status 的判断逻辑
+func daemonSetRolloutStatus(daemonSet *appsv1.DaemonSet) (string, bool, error) {
+	if daemonSet.Generation > daemonSet.Status.ObservedGeneration {
+		return "waiting for daemonset spec update to be observed...", false, nil
+	}
+
+	status := daemonSet.Status
+	if status.UpdatedNumberScheduled < status.DesiredNumberScheduled {
+		return fmt.Sprintf("waiting for rollout to finish: %d out of %d new pods have been updated...", status.UpdatedNumberScheduled, status.DesiredNumberScheduled), false, nil
+	}
+	if status.NumberAvailable < status.DesiredNumberScheduled {
+		return fmt.Sprintf("waiting for rollout to finish: %d of %d updated pods are available...", status.NumberAvailable, status.DesiredNumberScheduled), false, nil
+	}
+	return fmt.Sprintf("%d of %d updated pods are available", status.NumberAvailable, status.DesiredNumberScheduled), true, nil
+}
@@ -25,17 +25,57 @@ const (
 
 // DeploymentOptions 用于配置 Deployment 创建或更新的选项
 type DeploymentOptions struct {
+	Name                    string
+	Namespace               string
+	Replicas                int32
+	Image                   string
+	Port                    int32
+	ExtraPorts              []ContainerPort // 除 http 以外的命名端口，如 metrics、grpc
+	Strategy                string
+	RollingUpdate           RollingUpdate
+	BlueGreen               BlueGreen
+	Canary                  Canary
+	ProgressDeadlineSeconds int32
+	Quota                   Quota
+	EnvVars                 []string
+	LivenessProbe           LivenessProbe
+	ReadinessProbe          ReadinessProbe
+	VolumeMount             VolumeMount
+	SharedVolumes           []string    // name:path，emptyDir 卷挂载到 app 容器的 path，供 sidecar 共享
+	Sidecars                []Container // 与 app 容器运行在同一个 pod 中的其他容器
+	InitContainers          []InitContainer
+	ConfigMap               ConfigSource
+	Secret                  ConfigSource
+	ConfigHash              string // ConfigMap 和 Secret 内容的哈希，变化时触发 pod 重建
+	HPAEnabled              bool   // 副本数交由 HPA 管理，应用时不再设置 replicas
+	DisableAutomountToken   bool   // 不在 pod 中挂载 ServiceAccount 的 token
+	Color                   string // 蓝绿发布时的颜色，为空表示普通的滚动更新
+}
+
+type ContainerPort struct {
+	Name string
+	Port int32
+}
+
+// sidecar 容器，如日志收集、代理等
+type Container struct {
 	Name           string
-	Namespace      string
-	Replicas       int32
 	Image          string
-	Port           int32
-	RollingUpdate  RollingUpdate
-	Quota          Quota
+	Ports          []int32
 	EnvVars        []string
-	LivenessProbe  LivenessProbe
-	ReadinessProbe ReadinessProbe
-	VolumeMount    VolumeMount
+	Quota          Quota
+	LivenessProbe  LivenessProbe  // httpget 和 tcpsocket 类型使用第一个端口
+	ReadinessProbe ReadinessProbe // httpget 和 tcpsocket 类型使用第一个端口
+	VolumeMounts   []string       // name:path，name 为共享卷、config、secret，或者启用持久化存储时的 data
+}
+
+// init 容器，在 app 容器启动前按顺序运行，如等待数据库、拉取配置、修正卷权限等
+type InitContainer struct {
+	Name         string
+	Image        string // 为空时使用 app 镜像
+	Command      string // 通过 /bin/sh -c 执行
+	EnvVars      []string
+	VolumeMounts []string // name:path，name 为共享卷、config、secret，或者启用持久化存储时的 data
 }
 
 type RollingUpdate struct {
@@ -84,18 +124,32 @@ type VolumeMount struct {
 }
 
 func CreateOrUpdateDeployment(clientset *kubernetes.Clientset, ctx context.Context, opts DeploymentOptions) error {
+	deployment, err := NewDeployment(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = apply(ctx, clientset.AppsV1().Deployments(opts.Namespace), deployment, "deployment")
+	return err
+}
+
+// 根据选项构建 Deployment 对象，不访问集群
+func NewDeployment(opts DeploymentOptions) (*appsv1.Deployment, error) {
 	maxSurge := intstr.Parse(opts.RollingUpdate.MaxSurge)
 	maxUnavailable := intstr.Parse(opts.RollingUpdate.MaxUnavailable)
 
+	template, err := NewPodTemplate(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      opts.Name,
+			Name:      DeploymentName(opts.Name, opts.Color),
 			Namespace: opts.Namespace,
 		},
 
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &opts.Replicas,
-
 			Strategy: appsv1.DeploymentStrategy{
 				Type: appsv1.RollingUpdateDeploymentStrategyType,
 				RollingUpdate: &appsv1.RollingUpdateDeployment{
@@ -105,105 +159,253 @@ func CreateOrUpdateDeployment(clientset *kubernetes.Clientset, ctx context.Conte
 			},
 
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"name": opts.Name,
-				},
+				MatchLabels: podLabels(opts.Name, opts.Color),
 			},
 
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"name": opts.Name,
-					},
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
+			Template: template,
+		},
+	}
+
+	if !opts.HPAEnabled {
+		deployment.Spec.Replicas = &opts.Replicas
+	}
+	if opts.ProgressDeadlineSeconds > 0 {
+		deployment.Spec.ProgressDeadlineSeconds = &opts.ProgressDeadlineSeconds
+	}
+
+	return deployment, nil
+}
+
+// 构建 app 的 pod 模板，Deployment 和其他工作负载共用
+func NewPodTemplate(opts DeploymentOptions) (corev1.PodTemplateSpec, error) {
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: podLabels(opts.Name, opts.Color),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:            opts.Name,
+					Image:           opts.Image,
+					ImagePullPolicy: corev1.PullAlways,
+					Ports: []corev1.ContainerPort{
 						{
-							Name:            opts.Name,
-							Image:           opts.Image,
-							ImagePullPolicy: corev1.PullAlways,
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: opts.Port,
-								},
-							},
+							Name:          httpPortName,
+							ContainerPort: opts.Port,
 						},
 					},
-					ServiceAccountName: opts.Name,
 				},
 			},
+			ServiceAccountName: opts.Name,
 		},
 	}
 
-	container := deployment.Spec.Template.Spec.Containers[0]
-	if err := setResource(&container, opts); err != nil {
-		return fmt.Errorf("failed to set resource: %v", err)
+	container := template.Spec.Containers[0]
+	for _, port := range opts.ExtraPorts {
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.Port,
+		})
 	}
-	if err := setLivenessProbe(&container, opts); err != nil {
-		return fmt.Errorf("failed to set liveness probe: %v", err)
+	if err := setResource(&container, opts.Quota); err != nil {
+		return template, fmt.Errorf("failed to set resource: %v", err)
 	}
-	if err := setReadinessProbe(&container, opts); err != nil {
-		return fmt.Errorf("failed to set readiness probe: %v", err)
+	if err := setLivenessProbe(&container, opts.LivenessProbe, opts.Port); err != nil {
+		return template, fmt.Errorf("failed to set liveness probe: %v", err)
 	}
-	if err := setEnv(&container, opts); err != nil {
-		return fmt.Errorf("failed to set env: %v", err)
+	if err := setReadinessProbe(&container, opts.ReadinessProbe, opts.Port); err != nil {
+		return template, fmt.Errorf("failed to set readiness probe: %v", err)
+	}
+	if err := setEnv(&container, opts.EnvVars); err != nil {
+		return template, fmt.Errorf("failed to set env: %v", err)
 	}
-	deployment.Spec.Template.Spec.Containers[0] = container
 
+	// pvc 与 app 同名，由 CreateOrUpdatePVC 创建
+	volumes := map[string]bool{}
 	if opts.VolumeMount.Enabled {
-		pvc, err := clientset.CoreV1().PersistentVolumeClaims(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to get pvc: %v", err)
-		}
-
-		deployment.Spec.Template.Spec.Volumes = []corev1.Volume{
-			{
-				Name: "data",
-				VolumeSource: corev1.VolumeSource{
-					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-						ClaimName: pvc.Name,
-					},
+		template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+			Name: "data",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: opts.Name,
 				},
 			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "data",
+			MountPath: opts.VolumeMount.MountPath,
+		})
+		volumes["data"] = true
+	}
+
+	setConfig(&template.Spec, &container, opts, volumes)
+	if opts.ConfigHash != "" {
+		template.Annotations = map[string]string{
+			configHashAnnotation: opts.ConfigHash,
 		}
+	}
 
-		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
-			{
-				Name:      "data",
-				MountPath: opts.VolumeMount.MountPath,
-			},
+	for _, sharedVolume := range opts.SharedVolumes {
+		volumeMount, err := parseVolumeMount(sharedVolume)
+		if err != nil {
+			return template, fmt.Errorf("failed to set shared volume: %v", err)
+		}
+		if volumes[volumeMount.Name] {
+			return template, fmt.Errorf("failed to set shared volume: duplicate volume '%s'", volumeMount.Name)
 		}
+		template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+			Name: volumeMount.Name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, volumeMount)
+		volumes[volumeMount.Name] = true
 	}
+	template.Spec.Containers[0] = container
 
-	_, err := clientset.AppsV1().Deployments(opts.Namespace).Create(ctx, deployment, metav1.CreateOptions{})
-	if err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create deployment resource: %v", err)
+	for _, sidecar := range opts.Sidecars {
+		if sidecar.Name == opts.Name {
+			return template, fmt.Errorf("failed to set sidecar: name '%s' is used by the app container", sidecar.Name)
 		}
-		fmt.Println("deployment resource already exists, attempting update...")
-		_, err := clientset.AppsV1().Deployments(opts.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+		container, err := newSidecar(sidecar, volumes)
 		if err != nil {
-			return fmt.Errorf("failed to update deployment resource: %v", err)
+			return template, fmt.Errorf("failed to set sidecar %s: %v", sidecar.Name, err)
 		}
-		fmt.Println("deployment resource successfully updated")
-	} else {
-		fmt.Println("deployment resource successfully created")
+		template.Spec.Containers = append(template.Spec.Containers, container)
+	}
+
+	for _, initContainer := range opts.InitContainers {
+		container, err := newInitContainer(initContainer, opts.Image, volumes)
+		if err != nil {
+			return template, fmt.Errorf("failed to set init container %s: %v", initContainer.Name, err)
+		}
+		template.Spec.InitContainers = append(template.Spec.InitContainers, container)
+	}
+
+	// pod 上的设置优先于 ServiceAccount 上的
+	if opts.DisableAutomountToken {
+		automount := false
+		template.Spec.AutomountServiceAccountToken = &automount
 	}
 
+	return template, nil
+}
+
+func newSidecar(sidecar Container, volumes map[string]bool) (corev1.Container, error) {
+	container := corev1.Container{
+		Name:  sidecar.Name,
+		Image: sidecar.Image,
+	}
+	if helpers.IsBlank(sidecar.Image) {
+		return container, fmt.Errorf("image is required")
+	}
+
+	var port int32
+	for _, containerPort := range sidecar.Ports {
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			ContainerPort: containerPort,
+		})
+	}
+	if len(sidecar.Ports) > 0 {
+		port = sidecar.Ports[0]
+	}
+	if port == 0 && (needsPort(sidecar.LivenessProbe.Enabled, sidecar.LivenessProbe.Type) || needsPort(sidecar.ReadinessProbe.Enabled, sidecar.ReadinessProbe.Type)) {
+		return container, fmt.Errorf("ports are required by httpget and tcpsocket probes")
+	}
+
+	if err := setResource(&container, sidecar.Quota); err != nil {
+		return container, fmt.Errorf("failed to set resource: %v", err)
+	}
+	if err := setLivenessProbe(&container, sidecar.LivenessProbe, port); err != nil {
+		return container, fmt.Errorf("failed to set liveness probe: %v", err)
+	}
+	if err := setReadinessProbe(&container, sidecar.ReadinessProbe, port); err != nil {
+		return container, fmt.Errorf("failed to set readiness probe: %v", err)
+	}
+	if err := setEnv(&container, sidecar.EnvVars); err != nil {
+		return container, fmt.Errorf("failed to set env: %v", err)
+	}
+
+	if err := setVolumeMounts(&container, sidecar.VolumeMounts, volumes); err != nil {
+		return container, fmt.Errorf("failed to set volume mount: %v", err)
+	}
+
+	return container, nil
+}
+
+func newInitContainer(initContainer InitContainer, appImage string, volumes map[string]bool) (corev1.Container, error) {
+	container := corev1.Container{
+		Name:  initContainer.Name,
+		Image: initContainer.Image,
+	}
+	if helpers.IsBlank(initContainer.Image) {
+		container.Image = appImage
+		container.ImagePullPolicy = corev1.PullAlways
+	}
+	if helpers.IsBlank(initContainer.Command) {
+		return container, fmt.Errorf("command is required")
+	}
+	container.Command = []string{
+		"/bin/sh",
+		"-c",
+		initContainer.Command,
+	}
+
+	if err := setEnv(&container, initContainer.EnvVars); err != nil {
+		return container, fmt.Errorf("failed to set env: %v", err)
+	}
+	if err := setVolumeMounts(&container, initContainer.VolumeMounts, volumes); err != nil {
+		return container, fmt.Errorf("failed to set volume mount: %v", err)
+	}
+
+	return container, nil
+}
+
+// 只能挂载 pod 中已经声明的卷
+func setVolumeMounts(container *corev1.Container, mounts []string, volumes map[string]bool) error {
+	for _, mount := range mounts {
+		volumeMount, err := parseVolumeMount(mount)
+		if err != nil {
+			return err
+		}
+		if !volumes[volumeMount.Name] {
+			return fmt.Errorf("volume '%s' is not declared in the pod", volumeMount.Name)
+		}
+		container.VolumeMounts = append(container.VolumeMounts, volumeMount)
+	}
 	return nil
 }
 
-func DeleteDeployment(clientset *kubernetes.Clientset, ctx context.Context, opts DeploymentOptions) error {
-	err := clientset.AppsV1().Deployments(opts.Namespace).Delete(ctx, opts.Name, metav1.DeleteOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		return fmt.Errorf("failed to delete deployment resource: %v", err)
+func needsPort(enabled bool, probeType string) bool {
+	return enabled && strings.ToLower(probeType) != ProbeTypeExec
+}
+
+// 解析 name:path 格式的卷挂载
+func parseVolumeMount(input string) (corev1.VolumeMount, error) {
+	parts := strings.SplitN(input, ":", 2)
+	if len(parts) != 2 || helpers.IsBlank(parts[0]) || helpers.IsBlank(parts[1]) {
+		return corev1.VolumeMount{}, fmt.Errorf("invalid format for volume mount, expected 'name:path', got '%s'", input)
 	}
+	return corev1.VolumeMount{
+		Name:      strings.TrimSpace(parts[0]),
+		MountPath: strings.TrimSpace(parts[1]),
+	}, nil
+}
+
+func DeploymentExists(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string) (bool, error) {
+	_, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
-		fmt.Printf("deployment resource %s in namespace %s not found, no action taken\n", opts.Name, opts.Namespace)
-	} else {
-		fmt.Printf("deployment resource %s in namespace %s successfully deleted\n", opts.Name, opts.Namespace)
+		return false, nil
 	}
-	return nil
+	if err != nil {
+		return false, fmt.Errorf("failed to get deployment resource: %v", err)
+	}
+	return true, nil
+}
+
+func DeleteDeployment(clientset *kubernetes.Clientset, ctx context.Context, opts DeploymentOptions) error {
+	return remove(ctx, clientset.AppsV1().Deployments(opts.Namespace), opts.Name, opts.Namespace, "deployment", false)
 }
 
 func parseCPUSize(input string) (*resource.Quantity, error) {
@@ -256,17 +458,17 @@ func parseMemorySize(input string) (*resource.Quantity, error) {
 	return resource.NewQuantity(bytesValue, resource.BinarySI), nil
 }
 
-func setResource(container *corev1.Container, opts DeploymentOptions) error {
+func setResource(container *corev1.Container, quota Quota) error {
 	limits := corev1.ResourceList{}
-	if !helpers.IsBlank(opts.Quota.CPULimit) {
-		cpuLimit, err := parseCPUSize(strings.ToLower(opts.Quota.CPULimit))
+	if !helpers.IsBlank(quota.CPULimit) {
+		cpuLimit, err := parseCPUSize(strings.ToLower(quota.CPULimit))
 		if err != nil {
 			return err
 		}
 		limits[corev1.ResourceCPU] = *cpuLimit
 	}
-	if !helpers.IsBlank(opts.Quota.MemLimit) {
-		memLimit, err := parseMemorySize(strings.ToLower(opts.Quota.MemLimit))
+	if !helpers.IsBlank(quota.MemLimit) {
+		memLimit, err := parseMemorySize(strings.ToLower(quota.MemLimit))
 		if err != nil {
 			return err
 		}
@@ -274,15 +476,15 @@ func setResource(container *corev1.Container, opts DeploymentOptions) error {
 	}
 
 	requests := corev1.ResourceList{}
-	if !helpers.IsBlank(opts.Quota.CPURequest) {
-		cpuRequest, err := parseCPUSize(strings.ToLower(opts.Quota.CPURequest))
+	if !helpers.IsBlank(quota.CPURequest) {
+		cpuRequest, err := parseCPUSize(strings.ToLower(quota.CPURequest))
 		if err != nil {
 			return err
 		}
 		requests[corev1.ResourceCPU] = *cpuRequest
 	}
-	if !helpers.IsBlank(opts.Quota.MemRequest) {
-		memRequest, err := parseMemorySize(strings.ToLower(opts.Quota.MemRequest))
+	if !helpers.IsBlank(quota.MemRequest) {
+		memRequest, err := parseMemorySize(strings.ToLower(quota.MemRequest))
 		if err != nil {
 			return err
 		}
@@ -303,27 +505,30 @@ func setResource(container *corev1.Container, opts DeploymentOptions) error {
 	return nil
 }
 
-func setLivenessProbe(container *corev1.Container, opts DeploymentOptions) error {
-	if !opts.LivenessProbe.Enabled {
+func setLivenessProbe(container *corev1.Container, opts LivenessProbe, port int32) error {
+	if !opts.Enabled {
 		return nil
 	}
 
 	var probe Probe
-	probeType := strings.ToLower(opts.LivenessProbe.Type)
+	probeType := strings.ToLower(opts.Type)
 	switch probeType {
 	case ProbeTypeHTTPGet:
 		probe = HttpGetProbe{
-			Path:   opts.LivenessProbe.Path,
-			Port:   intstr.FromInt32(opts.Port),
-			Scheme: corev1.URIScheme(strings.ToUpper(opts.LivenessProbe.Scheme)),
+			Path:        opts.Path,
+			Port:        intstr.FromInt32(port),
+			Scheme:      corev1.URIScheme(strings.ToUpper(opts.Scheme)),
+			ProbeParams: opts.ProbeParams,
 		}
 	case ProbeTypeExec:
 		probe = ExecProbe{
-			Command: opts.LivenessProbe.Command,
+			Command:     opts.Command,
+			ProbeParams: opts.ProbeParams,
 		}
 	case ProbeTypeTCPSocket:
 		probe = TCPSocketProbe{
-			Port: intstr.FromInt32(opts.Port),
+			Port:        intstr.FromInt32(port),
+			ProbeParams: opts.ProbeParams,
 		}
 	default:
 		return fmt.Errorf("unsupported liveness probe type: '%s'", probeType)
@@ -333,27 +538,30 @@ func setLivenessProbe(container *corev1.Container, opts DeploymentOptions) error
 	return nil
 }
 
-func setReadinessProbe(container *corev1.Container, opts DeploymentOptions) error {
-	if !opts.ReadinessProbe.Enabled {
+func setReadinessProbe(container *corev1.Container, opts ReadinessProbe, port int32) error {
+	if !opts.Enabled {
 		return nil
 	}
 
 	var probe Probe
-	probeType := strings.ToLower(opts.ReadinessProbe.Type)
+	probeType := strings.ToLower(opts.Type)
 	switch probeType {
 	case ProbeTypeHTTPGet:
 		probe = HttpGetProbe{
-			Path:   opts.ReadinessProbe.Path,
-			Port:   intstr.FromInt32(opts.Port),
-			Scheme: corev1.URIScheme(strings.ToUpper(opts.ReadinessProbe.Scheme)),
+			Path:        opts.Path,
+			Port:        intstr.FromInt32(port),
+			Scheme:      corev1.URIScheme(strings.ToUpper(opts.Scheme)),
+			ProbeParams: opts.ProbeParams,
 		}
 	case ProbeTypeExec:
 		probe = ExecProbe{
-			Command: opts.ReadinessProbe.Command,
+			Command:     opts.Command,
+			ProbeParams: opts.ProbeParams,
 		}
 	case ProbeTypeTCPSocket:
 		probe = TCPSocketProbe{
-			Port: intstr.FromInt32(opts.Port),
+			Port:        intstr.FromInt32(port),
+			ProbeParams: opts.ProbeParams,
 		}
 	default:
 		return fmt.Errorf("unsupported readiness probe type: '%s'", probeType)
@@ -362,21 +570,3 @@ func setReadinessProbe(container *corev1.Container, opts DeploymentOptions) erro
 	container.ReadinessProbe = probe.GetProbe()
 	return nil
 }
-
-func setEnv(container *corev1.Container, opts DeploymentOptions) error {
-	var envs []corev1.EnvVar
-	for _, envVar := range opts.EnvVars {
-		parts := strings.Split(envVar, "=")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid format for environment variable: '%s'", envVar)
-		}
-		envs = append(envs, corev1.EnvVar{
-			Name:  parts[0],
-			Value: parts[1],
-		})
-	}
-	if len(envs) > 0 {
-		container.Env = envs
-	}
-	return nil
-}
@@ -0,0 +1,83 @@
+package kube
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewPodTemplateProbeParams(t *testing.T) {
+	params := ProbeParams{
+		InitialDelaySeconds: 10,
+		TimeoutSeconds:      3,
+		PeriodSeconds:       20,
+		SuccessThreshold:    1,
+		FailureThreshold:    5,
+	}
+	opts := DeploymentOptions{
+		Name:  "hellogo",
+		Image: "hellogo:v1",
+		Port:  8000,
+		LivenessProbe: LivenessProbe{
+			Enabled:     true,
+			Type:        ProbeTypeHTTPGet,
+			Path:        "/healthz",
+			Scheme:      "http",
+			ProbeParams: params,
+		},
+		ReadinessProbe: ReadinessProbe{
+			Enabled:     true,
+			Type:        ProbeTypeTCPSocket,
+			ProbeParams: params,
+		},
+		Sidecars: []Container{
+			{
+				Name:  "proxy",
+				Image: "envoy:v1",
+				Ports: []int32{9901},
+				LivenessProbe: LivenessProbe{
+					Enabled:     true,
+					Type:        ProbeTypeExec,
+					Command:     "true",
+					ProbeParams: params,
+				},
+				ReadinessProbe: ReadinessProbe{
+					Enabled:     true,
+					Type:        ProbeTypeHTTPGet,
+					Path:        "/ready",
+					ProbeParams: params,
+				},
+			},
+		},
+	}
+
+	template, err := NewPodTemplate(opts)
+	if err != nil {
+		t.Fatalf("NewPodTemplate() error = %v", err)
+	}
+	if len(template.Spec.Containers) != 2 {
+		t.Fatalf("NewPodTemplate() has %d containers, want 2", len(template.Spec.Containers))
+	}
+
+	for _, container := range template.Spec.Containers {
+		if container.LivenessProbe == nil || container.ReadinessProbe == nil {
+			t.Fatalf("container %s is missing probes", container.Name)
+		}
+		if got := probeParamsOf(container.LivenessProbe); got != params {
+			t.Errorf("liveness probe of container %s = %+v, want %+v", container.Name, got, params)
+		}
+		if got := probeParamsOf(container.ReadinessProbe); got != params {
+			t.Errorf("readiness probe of container %s = %+v, want %+v", container.Name, got, params)
+		}
+	}
+}
+
+func probeParamsOf(probe *corev1.Probe) ProbeParams {
+	return ProbeParams{
+		InitialDelaySeconds: probe.InitialDelaySeconds,
+		TimeoutSeconds:      probe.TimeoutSeconds,
+		PeriodSeconds:       probe.PeriodSeconds,
+		SuccessThreshold:    probe.SuccessThreshold,
+		FailureThreshold:    probe.FailureThreshold,
+	}
+}
@@ -0,0 +1,238 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+)
+
+const diffContextLines = 3
+
+// 将期望的对象与集群中正在运行的对象逐个对比，以统一差异格式输出，返回是否存在差异
+//
+// 期望的对象先以服务端应用的 dry-run 方式提交，这样默认值等由服务端填充的字段在两边一致
+func Diff(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, objs []runtime.Object, w io.Writer) (bool, error) {
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+
+	changed := false
+	for _, obj := range objs {
+		live, merged, err := dryRunApply(dynamicClient, mapper, ctx, obj)
+		if err != nil {
+			return changed, err
+		}
+
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		name, err := objectName(obj)
+		if err != nil {
+			return changed, err
+		}
+		path := fmt.Sprintf("%s/%s", strings.ToLower(gvk.Kind), name)
+
+		diff := unifiedDiff("live/"+path, "desired/"+path, yamlLines(live), yamlLines(merged))
+		if diff == "" {
+			continue
+		}
+		changed = true
+		if _, err := io.WriteString(w, diff); err != nil {
+			return changed, fmt.Errorf("failed to write diff: %v", err)
+		}
+	}
+	return changed, nil
+}
+
+// 返回集群中的对象和服务端应用后的对象，对象不存在时 live 为 nil
+func dryRunApply(dynamicClient dynamic.Interface, mapper meta.RESTMapper, ctx context.Context, obj runtime.Object) (map[string]interface{}, map[string]interface{}, error) {
+	desired, err := ToManifest(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find resource for %s: %v", gvk.Kind, err)
+	}
+
+	u := &unstructured.Unstructured{Object: desired}
+	var client dynamic.ResourceInterface = dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = dynamicClient.Resource(mapping.Resource).Namespace(u.GetNamespace())
+	}
+
+	var live map[string]interface{}
+	liveObj, err := client.Get(ctx, u.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, nil, fmt.Errorf("failed to get %s %s: %v", strings.ToLower(gvk.Kind), u.GetName(), err)
+	}
+	if err == nil {
+		live = liveObj.Object
+		cleanManifest(live)
+	}
+
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal %s %s: %v", strings.ToLower(gvk.Kind), u.GetName(), err)
+	}
+
+	force := true
+	mergedObj, err := client.Patch(ctx, u.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+		DryRun:       []string{metav1.DryRunAll},
+	})
+	if err != nil {
+		// 对象所在的命名空间尚未创建时无法 dry-run，直接使用期望的对象
+		if live == nil && apierrors.IsNotFound(err) {
+			return nil, desired, nil
+		}
+		return nil, nil, fmt.Errorf("failed to dry-run apply %s %s: %v", strings.ToLower(gvk.Kind), u.GetName(), err)
+	}
+
+	merged := mergedObj.Object
+	cleanManifest(merged)
+	return live, merged, nil
+}
+
+func yamlLines(manifest map[string]interface{}) []string {
+	if manifest == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+type diffLine struct {
+	op   byte // ' ', '-' 或 '+'
+	text string
+}
+
+// 基于最长公共子序列生成统一差异格式，两边相同时返回空字符串
+func unifiedDiff(fromName, toName string, a, b []string) string {
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	// 当前行在 a、b 中的行号（从 0 开始）
+	aLine, bLine := 0, 0
+	for start := 0; start < len(lines); {
+		// 找到下一处改动
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		for k := start; k < first; k++ {
+			aLine++
+			bLine++
+		}
+
+		// 向前包含上下文，并合并间隔不超过两倍上下文的改动
+		hunkStart := first - diffContextLines
+		if hunkStart < start {
+			hunkStart = start
+		}
+		aStart, bStart := aLine-(first-hunkStart), bLine-(first-hunkStart)
+		end := first
+		for k := first; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+				continue
+			}
+			if k-end >= 2*diffContextLines {
+				break
+			}
+		}
+		hunkEnd := end + diffContextLines
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+
+		aCount, bCount := 0, 0
+		var body strings.Builder
+		for k := hunkStart; k < hunkEnd; k++ {
+			line := lines[k]
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+			body.WriteByte(line.op)
+			body.WriteString(line.text)
+			body.WriteByte('\n')
+		}
+		for k := first; k < hunkEnd; k++ {
+			if lines[k].op != '+' {
+				aLine++
+			}
+			if lines[k].op != '-' {
+				bLine++
+			}
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		out.WriteString(body.String())
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	// l01 到 l20，按需替换其中的行
+	lines := func(replace map[int]string) []string {
+		var result []string
+		for i := 1; i <= 20; i++ {
+			line := fmt.Sprintf("l%02d", i)
+			if text, ok := replace[i]; ok {
+				line = text
+			}
+			result = append(result, line)
+		}
+		return result
+	}
+	insert := func(a []string, index int, line string) []string {
+		result := append([]string{}, a[:index]...)
+		result = append(result, line)
+		return append(result, a[index:]...)
+	}
+	remove := func(a []string, index int) []string {
+		result := append([]string{}, a[:index]...)
+		return append(result, a[index+1:]...)
+	}
+	header := "--- live\n+++ desired\n"
+
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want string
+	}{
+		{
+			name: "no change",
+			a:    lines(nil),
+			b:    lines(nil),
+			want: "",
+		},
+		{
+			name: "both empty",
+			want: "",
+		},
+		{
+			name: "pure insert",
+			a:    lines(nil),
+			b:    insert(lines(nil), 10, "x"),
+			want: header + "@@ -8,6 +8,7 @@\n l08\n l09\n l10\n+x\n l11\n l12\n l13\n",
+		},
+		{
+			name: "pure delete",
+			a:    lines(nil),
+			b:    remove(lines(nil), 9),
+			want: header + "@@ -7,7 +7,6 @@\n l07\n l08\n l09\n-l10\n l11\n l12\n l13\n",
+		},
+		{
+			name: "change at start",
+			a:    lines(nil),
+			b:    lines(map[int]string{1: "x01"}),
+			want: header + "@@ -1,4 +1,4 @@\n-l01\n+x01\n l02\n l03\n l04\n",
+		},
+		{
+			name: "change at end",
+			a:    lines(nil),
+			b:    lines(map[int]string{20: "x20"}),
+			want: header + "@@ -17,4 +17,4 @@\n l17\n l18\n l19\n-l20\n+x20\n",
+		},
+		{
+			name: "insert before first line",
+			a:    lines(nil),
+			b:    insert(lines(nil), 0, "x"),
+			want: header + "@@ -1,3 +1,4 @@\n+x\n l01\n l02\n l03\n",
+		},
+		{
+			name: "append after last line",
+			a:    lines(nil),
+			b:    append(lines(nil), "x"),
+			want: header + "@@ -18,3 +18,4 @@\n l18\n l19\n l20\n+x\n",
+		},
+		{
+			name: "new object",
+			b:    []string{"kind: Service", "metadata:"},
+			want: header + "@@ -0,0 +1,2 @@\n+kind: Service\n+metadata:\n",
+		},
+		{
+			name: "deleted object",
+			a:    []string{"kind: Service", "metadata:"},
+			want: header + "@@ -1,2 +0,0 @@\n-kind: Service\n-metadata:\n",
+		},
+		{
+			name: "changes within the context window are merged",
+			a:    lines(nil),
+			b:    lines(map[int]string{5: "x05", 11: "x11", 12: "x12"}),
+			want: header + "@@ -2,14 +2,14 @@\n l02\n l03\n l04\n-l05\n+x05\n l06\n l07\n l08\n l09\n l10\n-l11\n-l12\n+x11\n+x12\n l13\n l14\n l15\n",
+		},
+		{
+			name: "gap of exactly twice the context is merged",
+			a:    lines(nil),
+			b:    lines(map[int]string{5: "x05", 12: "x12"}),
+			want: header + "@@ -2,14 +2,14 @@\n l02\n l03\n l04\n-l05\n+x05\n l06\n l07\n l08\n l09\n l10\n l11\n-l12\n+x12\n l13\n l14\n l15\n",
+		},
+		{
+			name: "wider gaps start new hunks",
+			a:    lines(nil),
+			b:    lines(map[int]string{2: "x02", 10: "x10", 20: "x20"}),
+			want: header +
+				"@@ -1,5 +1,5 @@\n l01\n-l02\n+x02\n l03\n l04\n l05\n" +
+				"@@ -7,7 +7,7 @@\n l07\n l08\n l09\n-l10\n+x10\n l11\n l12\n l13\n" +
+				"@@ -17,4 +17,4 @@\n l17\n l18\n l19\n-l20\n+x20\n",
+		},
+		{
+			name: "line numbers after an insert",
+			a:    lines(nil),
+			b:    insert(lines(map[int]string{15: "x15"}), 2, "x"),
+			want: header +
+				"@@ -1,5 +1,6 @@\n l01\n l02\n+x\n l03\n l04\n l05\n" +
+				"@@ -12,7 +13,7 @@\n l12\n l13\n l14\n-l15\n+x15\n l16\n l17\n l18\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("live", "desired", tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/guobinqiu/appdeployer/docker"
 	"github.com/guobinqiu/appdeployer/helpers"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -22,12 +21,22 @@ type DockerSecretOptions struct {
 }
 
 func CreateOrUpdateDockerSecret(clientset *kubernetes.Clientset, ctx context.Context, opts DockerSecretOptions) error {
-	dockerconfigjson, err := buildDockerAuthConfig(opts.DockerOptions)
+	secret, err := NewDockerSecret(opts)
 	if err != nil {
 		return err
 	}
 
-	secret := &corev1.Secret{
+	_, err = apply(ctx, clientset.CoreV1().Secrets(opts.Namespace), secret, "docker secret")
+	return err
+}
+
+func NewDockerSecret(opts DockerSecretOptions) (*corev1.Secret, error) {
+	dockerconfigjson, err := buildDockerAuthConfig(opts.DockerOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "docker-" + opts.Name,
 			Namespace: opts.Namespace,
@@ -36,25 +45,18 @@ func CreateOrUpdateDockerSecret(clientset *kubernetes.Clientset, ctx context.Con
 		Data: map[string][]byte{
 			".dockerconfigjson": dockerconfigjson,
 		},
-	}
-
-	if _, err = clientset.CoreV1().Secrets(opts.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create docker secret resource: %v", err)
-		}
-		fmt.Println("docker secret resource successfully updated")
-	} else {
-		fmt.Println("docker secret resource successfully created")
-	}
+	}, nil
+}
 
-	return nil
+func DeleteDockerSecret(clientset *kubernetes.Clientset, ctx context.Context, opts DockerSecretOptions) error {
+	return remove(ctx, clientset.CoreV1().Secrets(opts.Namespace), "docker-"+opts.Name, opts.Namespace, "docker secret", false)
 }
 
 func buildDockerAuthConfig(opts docker.DockerOptions) ([]byte, error) {
 	var dockerConfig map[string]interface{}
 
 	if !helpers.IsBlank(opts.Registry) && !helpers.IsBlank(opts.Username) && !helpers.IsBlank(opts.Password) {
-		fmt.Println("Using username password auth")
+		fmt.Fprintln(os.Stderr, "Using username password auth")
 
 		// 构造Docker配置信息
 		dockerConfig = map[string]interface{}{
@@ -65,7 +67,7 @@ func buildDockerAuthConfig(opts docker.DockerOptions) ([]byte, error) {
 			},
 		}
 	} else if !helpers.IsBlank(opts.Dockerconfig) {
-		fmt.Println("Using config file auth: " + opts.Dockerconfig)
+		fmt.Fprintln(os.Stderr, "Using config file auth: "+opts.Dockerconfig)
 
 		//读取Docker配置文件
 		configData, err := os.ReadFile(opts.Dockerconfig)
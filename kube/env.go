@@ -0,0 +1,127 @@
+package kube
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// 环境变量的值以这些前缀开头时表示引用，否则为字面值
+const (
+	envSecretPrefix    = "secret://"    // secret://<name>/<key>
+	envConfigMapPrefix = "configmap://" // configmap://<name>/<key>
+	envFieldPrefix     = "field://"     // field://metadata.name，downward API 的 pod 字段
+	envResourcePrefix  = "resource://"  // resource://limits.cpu，downward API 的容器资源
+)
+
+func setEnv(container *corev1.Container, envVars []string) error {
+	var envs []corev1.EnvVar
+	for _, envVar := range envVars {
+		env, err := parseEnvVar(envVar)
+		if err != nil {
+			return err
+		}
+		envs = append(envs, env)
+	}
+	if len(envs) > 0 {
+		container.Env = envs
+	}
+	return nil
+}
+
+// 一个 -e 可以用逗号分隔多个变量，值中的逗号写作 \,
+func SplitEnvVars(values []string) []string {
+	var envVars []string
+	for _, value := range values {
+		var current strings.Builder
+		for i := 0; i < len(value); i++ {
+			switch {
+			case value[i] == '\\' && i+1 < len(value) && value[i+1] == ',':
+				current.WriteByte(',')
+				i++
+			case value[i] == ',':
+				if current.Len() > 0 {
+					envVars = append(envVars, current.String())
+				}
+				current.Reset()
+			default:
+				current.WriteByte(value[i])
+			}
+		}
+		if current.Len() > 0 {
+			envVars = append(envVars, current.String())
+		}
+	}
+	return envVars
+}
+
+// 只按第一个 = 拆分，值中可以包含 =
+func parseEnvVar(envVar string) (corev1.EnvVar, error) {
+	name, value, found := strings.Cut(envVar, "=")
+	if !found || strings.TrimSpace(name) == "" {
+		return corev1.EnvVar{}, fmt.Errorf("invalid format for environment variable: '%s'", envVar)
+	}
+	env := corev1.EnvVar{
+		Name: strings.TrimSpace(name),
+	}
+
+	switch {
+	case strings.HasPrefix(value, envSecretPrefix):
+		refName, key, err := parseKeyRef(strings.TrimPrefix(value, envSecretPrefix))
+		if err != nil {
+			return env, fmt.Errorf("invalid secret reference for environment variable %s: %v", env.Name, err)
+		}
+		env.ValueFrom = &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: refName},
+				Key:                  key,
+			},
+		}
+	case strings.HasPrefix(value, envConfigMapPrefix):
+		refName, key, err := parseKeyRef(strings.TrimPrefix(value, envConfigMapPrefix))
+		if err != nil {
+			return env, fmt.Errorf("invalid configmap reference for environment variable %s: %v", env.Name, err)
+		}
+		env.ValueFrom = &corev1.EnvVarSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: refName},
+				Key:                  key,
+			},
+		}
+	case strings.HasPrefix(value, envFieldPrefix):
+		fieldPath := strings.TrimPrefix(value, envFieldPrefix)
+		if fieldPath == "" {
+			return env, fmt.Errorf("invalid field reference for environment variable %s: field path is required", env.Name)
+		}
+		env.ValueFrom = &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: fieldPath,
+			},
+		}
+	case strings.HasPrefix(value, envResourcePrefix):
+		resourceName := strings.TrimPrefix(value, envResourcePrefix)
+		if resourceName == "" {
+			return env, fmt.Errorf("invalid resource reference for environment variable %s: resource is required", env.Name)
+		}
+		env.ValueFrom = &corev1.EnvVarSource{
+			ResourceFieldRef: &corev1.ResourceFieldSelector{
+				Resource: resourceName,
+				Divisor:  resource.MustParse("1"),
+			},
+		}
+	default:
+		env.Value = value
+	}
+	return env, nil
+}
+
+// 解析 <name>/<key>
+func parseKeyRef(ref string) (string, string, error) {
+	name, key, found := strings.Cut(ref, "/")
+	if !found || name == "" || key == "" {
+		return "", "", fmt.Errorf("expected '<name>/<key>', got '%s'", ref)
+	}
+	return name, key, nil
+}
@@ -0,0 +1,175 @@
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseEnvVar(t *testing.T) {
+	tests := []struct {
+		envVar  string
+		want    corev1.EnvVar
+		wantErr bool
+	}{
+		{
+			envVar: "LOG_LEVEL=info",
+			want:   corev1.EnvVar{Name: "LOG_LEVEL", Value: "info"},
+		},
+		{
+			envVar: "EMPTY=",
+			want:   corev1.EnvVar{Name: "EMPTY"},
+		},
+		{
+			envVar: " NAME =value",
+			want:   corev1.EnvVar{Name: "NAME", Value: "value"},
+		},
+		{
+			envVar: "DSN=postgres://db:5432/app?sslmode=disable&user=app",
+			want:   corev1.EnvVar{Name: "DSN", Value: "postgres://db:5432/app?sslmode=disable&user=app"},
+		},
+		{
+			envVar: "JAVA_OPTS=-Da=1 -Db==2",
+			want:   corev1.EnvVar{Name: "JAVA_OPTS", Value: "-Da=1 -Db==2"},
+		},
+		{
+			envVar: "OUTPUTS=es,stdout",
+			want:   corev1.EnvVar{Name: "OUTPUTS", Value: "es,stdout"},
+		},
+		{
+			envVar: "LABELS=a=1,b=2",
+			want:   corev1.EnvVar{Name: "LABELS", Value: "a=1,b=2"},
+		},
+		{
+			envVar: "POD_NAME=field://metadata.name",
+			want: corev1.EnvVar{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			}},
+		},
+		{
+			envVar: "APP=field://metadata.labels['app.kubernetes.io/name']",
+			want: corev1.EnvVar{Name: "APP", ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['app.kubernetes.io/name']"},
+			}},
+		},
+		{
+			envVar:  "POD_NAME=field://",
+			wantErr: true,
+		},
+		{
+			envVar: "DB_PASSWORD=secret://db/password",
+			want: corev1.EnvVar{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "db"},
+					Key:                  "password",
+				},
+			}},
+		},
+		{
+			envVar:  "DB_PASSWORD=secret://db",
+			wantErr: true,
+		},
+		{
+			envVar: "FEATURES=configmap://app/features",
+			want: corev1.EnvVar{Name: "FEATURES", ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "app"},
+					Key:                  "features",
+				},
+			}},
+		},
+		{
+			envVar:  "FEATURES=configmap:///features",
+			wantErr: true,
+		},
+		{
+			envVar: "CPU=resource://limits.cpu",
+			want: corev1.EnvVar{Name: "CPU", ValueFrom: &corev1.EnvVarSource{
+				ResourceFieldRef: &corev1.ResourceFieldSelector{Resource: "limits.cpu", Divisor: resource.MustParse("1")},
+			}},
+		},
+		{
+			envVar:  "CPU=resource://",
+			wantErr: true,
+		},
+		{
+			envVar:  "LOG_LEVEL",
+			wantErr: true,
+		},
+		{
+			envVar:  "=info",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.envVar, func(t *testing.T) {
+			got, err := parseEnvVar(tt.envVar)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseEnvVar() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEnvVar() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEnvVar() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitEnvVars(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{
+			name:   "one variable per flag",
+			values: []string{"A=1", "B=2"},
+			want:   []string{"A=1", "B=2"},
+		},
+		{
+			name:   "several variables in one flag",
+			values: []string{"A=1,B=2", "C=3"},
+			want:   []string{"A=1", "B=2", "C=3"},
+		},
+		{
+			name:   "escaped comma in value",
+			values: []string{`OUTPUTS=es\,stdout,B=2`},
+			want:   []string{"OUTPUTS=es,stdout", "B=2"},
+		},
+		{
+			name:   "other backslashes are kept",
+			values: []string{`PATTERN=a\d+`, `WIN=C:\app\`},
+			want:   []string{`PATTERN=a\d+`, `WIN=C:\app\`},
+		},
+		{
+			name:   "equal signs and references",
+			values: []string{"DSN=a=1&b=2,POD_NAME=field://metadata.name"},
+			want:   []string{"DSN=a=1&b=2", "POD_NAME=field://metadata.name"},
+		},
+		{
+			name:   "empty entries are skipped",
+			values: []string{"A=1,,B=2,", ""},
+			want:   []string{"A=1", "B=2"},
+		},
+		{
+			name: "no flags",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitEnvVars(tt.values)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitEnvVars() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
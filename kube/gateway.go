@@ -0,0 +1,312 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// 对外暴露 app 的方式，不区分大小写
+const (
+	RoutingIngress = "ingress"
+	RoutingGateway = "gateway"
+)
+
+const gatewayGroup = "gateway.networking.k8s.io"
+
+var (
+	httpRouteResource      = schema.GroupVersionResource{Group: gatewayGroup, Version: "v1", Resource: "httproutes"}
+	referenceGrantResource = schema.GroupVersionResource{Group: gatewayGroup, Version: "v1beta1", Resource: "referencegrants"}
+)
+
+// Gateway API 的 HTTPRoute，挂载到已有的 Gateway 上
+type GatewayOptions struct {
+	Name             string
+	Namespace        string
+	Gateway          string         // 父 Gateway 的名称
+	GatewayNamespace string         // 为空时与 app 相同
+	SectionName      string         // 处理 http 的 listener，为空表示所有 listener
+	HTTPSSectionName string         // 启用 TLS 时处理 https 的 listener
+	Ingress          IngressOptions // 复用 host、路径和 TLS 的选项
+	Service          ServiceOptions // backendRefs 需要端口号，按名称从 Service 中查找
+	Backends         []RouteBackend // 为空时全部流量转发到 app 的 Service
+}
+
+type RouteBackend struct {
+	Name   string // Service 名称
+	Port   int32  // 为 0 时使用路径的 Service 端口
+	Weight int32
+}
+
+// Gateway API 允许的最大权重
+const maxBackendWeight = 1000000
+
+// 解析 service:port:weight，权重之和必须大于 0
+func ParseRouteBackends(inputs []string) ([]RouteBackend, error) {
+	var backends []RouteBackend
+	seen := map[string]bool{}
+	var total int64
+	for _, input := range inputs {
+		parts := strings.Split(input, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid format for gateway backend, expected 'service:port:weight', got '%s'", input)
+		}
+		if errs := validation.IsDNS1035Label(parts[0]); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid service name of gateway backend '%s': %s", input, strings.Join(errs, ", "))
+		}
+		port, err := strconv.Atoi(parts[1])
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port of gateway backend '%s', expected 1-65535", input)
+		}
+		weight, err := strconv.Atoi(parts[2])
+		if err != nil || weight < 0 || weight > maxBackendWeight {
+			return nil, fmt.Errorf("invalid weight of gateway backend '%s', expected 0-%d", input, maxBackendWeight)
+		}
+		key := parts[0] + ":" + parts[1]
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate gateway backend %s", key)
+		}
+		seen[key] = true
+		total += int64(weight)
+		backends = append(backends, RouteBackend{Name: parts[0], Port: int32(port), Weight: int32(weight)})
+	}
+	if len(backends) > 0 && total == 0 {
+		return nil, fmt.Errorf("at least one gateway backend must have a weight greater than 0")
+	}
+	return backends, nil
+}
+
+func redirectRouteName(name string) string {
+	return name + "-redirect"
+}
+
+func referenceGrantName(name string) string {
+	return name + "-tls"
+}
+
+// 启用 TLS 时证书由 Gateway 的 https listener 引用，http listener 上的请求重定向到 https
+func CreateOrUpdateHTTPRoute(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, opts GatewayOptions) error {
+	route, err := NewHTTPRoute(opts)
+	if err != nil {
+		return err
+	}
+
+	routes := dynamicClient.Resource(httpRouteResource).Namespace(opts.Namespace)
+	grants := dynamicClient.Resource(referenceGrantResource).Namespace(opts.Namespace)
+	if opts.Ingress.TLS {
+		if err := CreateOrUpdateTLS(clientset, dynamicClient, ctx, opts.Ingress); err != nil {
+			return err
+		}
+		if grant := NewReferenceGrant(opts); grant != nil {
+			if _, err := apply(ctx, grants, grant, "referencegrant"); err != nil {
+				return err
+			}
+		}
+		if _, err := apply(ctx, routes, NewHTTPRedirectRoute(opts), "httproute"); err != nil {
+			return err
+		}
+	} else {
+		if err := remove(ctx, dynamicResource{routes}, redirectRouteName(opts.Name), opts.Namespace, "httproute", true); err != nil {
+			return err
+		}
+		if err := remove(ctx, dynamicResource{grants}, referenceGrantName(opts.Name), opts.Namespace, "referencegrant", true); err != nil {
+			return err
+		}
+	}
+
+	_, err = apply(ctx, routes, route, "httproute")
+	return err
+}
+
+// HTTPRoute 的 hostnames 作用于所有规则，因此各个 host 的路径必须相同
+func NewHTTPRoute(opts GatewayOptions) (*unstructured.Unstructured, error) {
+	hostRules := IngressRules(opts.Ingress)
+	for _, rule := range hostRules[1:] {
+		if !reflect.DeepEqual(rule.Paths, hostRules[0].Paths) {
+			return nil, fmt.Errorf("hosts of gateway routing must share the same paths, '%s' differs from '%s'", rule.Host, hostRules[0].Host)
+		}
+	}
+
+	backends := opts.Backends
+	if len(backends) == 0 {
+		backends = []RouteBackend{{Name: opts.Name, Weight: 1}}
+	}
+
+	var rules []interface{}
+	for _, path := range hostRules[0].Paths {
+		matchType, err := parsePathMatchType(path.PathType)
+		if err != nil {
+			return nil, err
+		}
+		servicePort := path.ServicePort
+		if servicePort == "" {
+			servicePort = opts.Ingress.ServicePort
+		}
+		port, err := servicePortNumber(opts.Service, servicePort)
+		if err != nil {
+			return nil, err
+		}
+
+		var backendRefs []interface{}
+		for _, backend := range backends {
+			backendPort := port
+			if backend.Port > 0 {
+				backendPort = backend.Port
+			}
+			backendRefs = append(backendRefs, map[string]interface{}{
+				"name":   backend.Name,
+				"port":   int64(backendPort),
+				"weight": int64(backend.Weight),
+			})
+		}
+		rules = append(rules, map[string]interface{}{
+			"matches": []interface{}{
+				map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":  matchType,
+						"value": path.Path,
+					},
+				},
+			},
+			"backendRefs": backendRefs,
+		})
+	}
+
+	sectionName := opts.SectionName
+	if opts.Ingress.TLS {
+		sectionName = opts.HTTPSSectionName
+	}
+	return newHTTPRoute(opts, opts.Name, sectionName, rules), nil
+}
+
+// 对应 Ingress 的 force-ssl-redirect
+func NewHTTPRedirectRoute(opts GatewayOptions) *unstructured.Unstructured {
+	rules := []interface{}{
+		map[string]interface{}{
+			"filters": []interface{}{
+				map[string]interface{}{
+					"type": "RequestRedirect",
+					"requestRedirect": map[string]interface{}{
+						"scheme":     "https",
+						"statusCode": int64(301),
+					},
+				},
+			},
+		},
+	}
+	// 挂载到所有 listener 时 https 上的请求也会被重定向，因此默认使用名为 http 的 listener
+	sectionName := opts.SectionName
+	if sectionName == "" {
+		sectionName = "http"
+	}
+	return newHTTPRoute(opts, redirectRouteName(opts.Name), sectionName, rules)
+}
+
+func newHTTPRoute(opts GatewayOptions, name, sectionName string, rules []interface{}) *unstructured.Unstructured {
+	parentRef := map[string]interface{}{
+		"name": opts.Gateway,
+	}
+	if opts.GatewayNamespace != "" {
+		parentRef["namespace"] = opts.GatewayNamespace
+	}
+	if sectionName != "" {
+		parentRef["sectionName"] = sectionName
+	}
+
+	var hostnames []interface{}
+	for _, host := range IngressHosts(opts.Ingress) {
+		hostnames = append(hostnames, host)
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": httpRouteResource.GroupVersion().String(),
+			"kind":       "HTTPRoute",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": opts.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{parentRef},
+				"hostnames":  hostnames,
+				"rules":      rules,
+			},
+		},
+	}
+}
+
+// Gateway 在其他命名空间时，需要授权它引用 app 命名空间中的 tls secret，同一命名空间时返回 nil
+func NewReferenceGrant(opts GatewayOptions) *unstructured.Unstructured {
+	if opts.GatewayNamespace == "" || opts.GatewayNamespace == opts.Namespace {
+		return nil
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": referenceGrantResource.GroupVersion().String(),
+			"kind":       "ReferenceGrant",
+			"metadata": map[string]interface{}{
+				"name":      referenceGrantName(opts.Name),
+				"namespace": opts.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"from": []interface{}{
+					map[string]interface{}{
+						"group":     gatewayGroup,
+						"kind":      "Gateway",
+						"namespace": opts.GatewayNamespace,
+					},
+				},
+				"to": []interface{}{
+					map[string]interface{}{
+						"group": "",
+						"kind":  "Secret",
+						"name":  "tls-" + opts.Name,
+					},
+				},
+			},
+		},
+	}
+}
+
+// 删除 HTTPRoute、重定向的 HTTPRoute 和 ReferenceGrant，集群中没有 Gateway API 时同样视为不存在
+func DeleteHTTPRoute(dynamicClient dynamic.Interface, ctx context.Context, name, namespace string, quiet bool) error {
+	routes := dynamicResource{dynamicClient.Resource(httpRouteResource).Namespace(namespace)}
+	for _, routeName := range []string{name, redirectRouteName(name)} {
+		if err := remove(ctx, routes, routeName, namespace, "httproute", quiet); err != nil {
+			return err
+		}
+	}
+	return remove(ctx, dynamicResource{dynamicClient.Resource(referenceGrantResource).Namespace(namespace)}, referenceGrantName(name), namespace, "referencegrant", quiet)
+}
+
+func parsePathMatchType(pathType string) (string, error) {
+	switch strings.ToLower(pathType) {
+	case "", "prefix":
+		return "PathPrefix", nil
+	case "exact":
+		return "Exact", nil
+	default:
+		return "", fmt.Errorf("unsupported path type for gateway routing: '%s'", pathType)
+	}
+}
+
+func servicePortNumber(opts ServiceOptions, name string) (int32, error) {
+	if name == "" || name == httpPortName {
+		return opts.Port, nil
+	}
+	for _, port := range opts.ExtraPorts {
+		if port.Name == name {
+			return port.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("service port '%s' not found", name)
+}
@@ -3,72 +3,371 @@ package kube
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/guobinqiu/appdeployer/helpers"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	HPAMetricPods     = "pods"
+	HPAMetricObject   = "object"
+	HPAMetricExternal = "external"
+
+	HPATargetValue        = "value"
+	HPATargetAverageValue = "averagevalue"
+
+	HPAPolicyPods    = "pods"
+	HPAPolicyPercent = "percent"
+
+	HPASelectPolicyMax      = "max"
+	HPASelectPolicyMin      = "min"
+	HPASelectPolicyDisabled = "disabled"
+)
+
 type HPAOptions struct {
 	Name        string
 	Namespace   string
 	Enabled     bool
 	MinReplicas int32
 	MaxReplicas int32
-	CPURate     int32
+	CPURate     int32  // CPU 平均使用率，相对于 request，0 表示不按 CPU 伸缩
+	CPUValue    string // CPU 平均用量，如 500m，设置后替代 CPURate
+	MemoryRate  int32  // 内存平均使用率，相对于 request，0 表示不按内存伸缩
+	MemoryValue string // 内存平均用量，如 512Mi，设置后替代 MemoryRate
+	Metrics     []HPAMetric
+	ScaleUp     *HPAScalingRules // 为空使用 k8s 默认行为
+	ScaleDown   *HPAScalingRules // 为空使用 k8s 默认行为
+	Color       string           // 蓝绿发布时伸缩的颜色
+	Workload    string           // 伸缩的工作负载类型，为空表示 Deployment
+}
+
+// 自定义指标，由 metrics adapter 提供
+type HPAMetric struct {
+	Type       string                                    // pods、object 或 external
+	Name       string                                    // 指标名
+	Selector   *metav1.LabelSelector                     // 指标的标签选择器，可为空
+	Object     autoscalingv2.CrossVersionObjectReference // object 指标所描述的对象
+	TargetType string                                    // value 或 averagevalue，pods 指标只能是 averagevalue
+	Target     resource.Quantity
+}
+
+type HPAScalingRules struct {
+	StabilizationWindowSeconds *int32 // 为空使用 k8s 默认值，扩容 0 秒，缩容 300 秒
+	SelectPolicy               string // max、min 或 disabled，为空表示 max
+	Policies                   []HPAScalingPolicy
+}
+
+// 每 Period 内最多伸缩 Value 个 pod 或 Value 百分比的 pod
+type HPAScalingPolicy struct {
+	Type   string // pods 或 percent
+	Value  int32
+	Period time.Duration
 }
 
 func CreateOrUpdateHPA(clientset *kubernetes.Clientset, ctx context.Context, opts HPAOptions) error {
+	hpa, err := NewHPA(opts)
+	if err != nil {
+		return err
+	}
+	_, err = apply(ctx, clientset.AutoscalingV2().HorizontalPodAutoscalers(opts.Namespace), hpa, "hpa")
+	return err
+}
+
+func NewHPA(opts HPAOptions) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	metrics, err := hpaMetrics(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("hpa requires at least one metric")
+	}
+
 	hpa := &autoscalingv2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      opts.Name,
 			Namespace: opts.Namespace,
 		},
 		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
-			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
-				APIVersion: "app/v1",
-				Kind:       "Deployment",
-				Name:       opts.Name,
+			ScaleTargetRef: scaleTargetRef(opts),
+			MinReplicas:    &opts.MinReplicas,
+			MaxReplicas:    opts.MaxReplicas,
+			Metrics:        metrics,
+		},
+	}
+	if opts.ScaleUp != nil || opts.ScaleDown != nil {
+		hpa.Spec.Behavior = &autoscalingv2.HorizontalPodAutoscalerBehavior{
+			ScaleUp:   hpaScalingRules(opts.ScaleUp),
+			ScaleDown: hpaScalingRules(opts.ScaleDown),
+		}
+	}
+	return hpa, nil
+}
+
+func hpaMetrics(opts HPAOptions) ([]autoscalingv2.MetricSpec, error) {
+	var metrics []autoscalingv2.MetricSpec
+
+	cpu, err := resourceMetric(corev1.ResourceCPU, opts.CPURate, opts.CPUValue, parseCPUSize)
+	if err != nil {
+		return nil, err
+	}
+	if cpu != nil {
+		metrics = append(metrics, *cpu)
+	}
+
+	memory, err := resourceMetric(corev1.ResourceMemory, opts.MemoryRate, opts.MemoryValue, parseMemorySize)
+	if err != nil {
+		return nil, err
+	}
+	if memory != nil {
+		metrics = append(metrics, *memory)
+	}
+
+	for _, metric := range opts.Metrics {
+		metrics = append(metrics, customMetric(metric))
+	}
+	return metrics, nil
+}
+
+func resourceMetric(name corev1.ResourceName, rate int32, value string, parse func(string) (*resource.Quantity, error)) (*autoscalingv2.MetricSpec, error) {
+	var target autoscalingv2.MetricTarget
+	switch {
+	case value != "":
+		quantity, err := parse(strings.ToLower(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse hpa %s value: %v", name, err)
+		}
+		target = autoscalingv2.MetricTarget{
+			Type:         autoscalingv2.AverageValueMetricType,
+			AverageValue: quantity,
+		}
+	case rate > 0:
+		target = autoscalingv2.MetricTarget{
+			Type:               autoscalingv2.UtilizationMetricType,
+			AverageUtilization: &rate,
+		}
+	default:
+		return nil, nil
+	}
+
+	return &autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:   name,
+			Target: target,
+		},
+	}, nil
+}
+
+func customMetric(metric HPAMetric) autoscalingv2.MetricSpec {
+	identifier := autoscalingv2.MetricIdentifier{
+		Name:     metric.Name,
+		Selector: metric.Selector,
+	}
+
+	target := metric.Target
+	metricTarget := autoscalingv2.MetricTarget{
+		Type:  autoscalingv2.ValueMetricType,
+		Value: &target,
+	}
+	if metric.TargetType == HPATargetAverageValue {
+		metricTarget = autoscalingv2.MetricTarget{
+			Type:         autoscalingv2.AverageValueMetricType,
+			AverageValue: &target,
+		}
+	}
+
+	switch metric.Type {
+	case HPAMetricPods:
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: identifier,
+				Target: metricTarget,
 			},
-			MinReplicas: &opts.MinReplicas,
-			MaxReplicas: opts.MaxReplicas,
-			Metrics: []autoscalingv2.MetricSpec{
-				{
-					Type: autoscalingv2.ResourceMetricSourceType,
-					Resource: &autoscalingv2.ResourceMetricSource{
-						Name: "cpu",
-						Target: autoscalingv2.MetricTarget{
-							Type:               autoscalingv2.UtilizationMetricType,
-							AverageUtilization: &opts.CPURate,
-						},
-					},
-				},
+		}
+	case HPAMetricObject:
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ObjectMetricSourceType,
+			Object: &autoscalingv2.ObjectMetricSource{
+				DescribedObject: metric.Object,
+				Metric:          identifier,
+				Target:          metricTarget,
 			},
-		},
+		}
+	default:
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ExternalMetricSourceType,
+			External: &autoscalingv2.ExternalMetricSource{
+				Metric: identifier,
+				Target: metricTarget,
+			},
+		}
+	}
+}
+
+func hpaScalingRules(rules *HPAScalingRules) *autoscalingv2.HPAScalingRules {
+	if rules == nil {
+		return nil
+	}
+
+	scalingRules := &autoscalingv2.HPAScalingRules{
+		StabilizationWindowSeconds: rules.StabilizationWindowSeconds,
+	}
+	switch rules.SelectPolicy {
+	case HPASelectPolicyMin:
+		scalingRules.SelectPolicy = policySelect(autoscalingv2.MinChangePolicySelect)
+	case HPASelectPolicyDisabled:
+		scalingRules.SelectPolicy = policySelect(autoscalingv2.DisabledPolicySelect)
+	case HPASelectPolicyMax:
+		scalingRules.SelectPolicy = policySelect(autoscalingv2.MaxChangePolicySelect)
+	}
+	for _, policy := range rules.Policies {
+		policyType := autoscalingv2.PodsScalingPolicy
+		if policy.Type == HPAPolicyPercent {
+			policyType = autoscalingv2.PercentScalingPolicy
+		}
+		scalingRules.Policies = append(scalingRules.Policies, autoscalingv2.HPAScalingPolicy{
+			Type:          policyType,
+			Value:         policy.Value,
+			PeriodSeconds: int32(policy.Period.Seconds()),
+		})
+	}
+	return scalingRules
+}
+
+func policySelect(selectPolicy autoscalingv2.ScalingPolicySelect) *autoscalingv2.ScalingPolicySelect {
+	return &selectPolicy
+}
+
+func scaleTargetRef(opts HPAOptions) autoscalingv2.CrossVersionObjectReference {
+	if opts.Workload == WorkloadStatefulSet {
+		return autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "StatefulSet",
+			Name:       opts.Name,
+		}
+	}
+	return autoscalingv2.CrossVersionObjectReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       DeploymentName(opts.Name, opts.Color),
+	}
+}
+
+// 格式为 pods:metric:averageValue、object:apiVersion/kind/name:metric:value[:targetType] 或 external:metric:value[:targetType]
+// metric 可以带标签选择器，如 queue_length{queue=orders&env=prod}
+func ParseHPAMetric(input string) (HPAMetric, error) {
+	parts := strings.Split(input, ":")
+	metric := HPAMetric{Type: strings.ToLower(parts[0])}
+
+	var rest []string
+	switch metric.Type {
+	case HPAMetricPods:
+		if len(parts) != 3 {
+			return HPAMetric{}, fmt.Errorf("invalid format for pods metric, expected 'pods:metric:averageValue', got '%s'", input)
+		}
+		metric.TargetType = HPATargetAverageValue
+		rest = parts[1:]
+	case HPAMetricObject:
+		if len(parts) != 4 && len(parts) != 5 {
+			return HPAMetric{}, fmt.Errorf("invalid format for object metric, expected 'object:apiVersion/kind/name:metric:value[:targetType]', got '%s'", input)
+		}
+		segments := strings.Split(parts[1], "/")
+		if len(segments) < 3 || helpers.IsBlank(segments[len(segments)-2]) || helpers.IsBlank(segments[len(segments)-1]) {
+			return HPAMetric{}, fmt.Errorf("invalid object '%s' in '%s', expected 'apiVersion/kind/name'", parts[1], input)
+		}
+		metric.Object = autoscalingv2.CrossVersionObjectReference{
+			APIVersion: strings.Join(segments[:len(segments)-2], "/"),
+			Kind:       segments[len(segments)-2],
+			Name:       segments[len(segments)-1],
+		}
+		metric.TargetType = HPATargetValue
+		rest = parts[2:]
+	case HPAMetricExternal:
+		if len(parts) != 3 && len(parts) != 4 {
+			return HPAMetric{}, fmt.Errorf("invalid format for external metric, expected 'external:metric:value[:targetType]', got '%s'", input)
+		}
+		metric.TargetType = HPATargetAverageValue
+		rest = parts[1:]
+	default:
+		return HPAMetric{}, fmt.Errorf("unsupported metric type '%s' in '%s', expected pods, object or external", parts[0], input)
 	}
 
-	if _, err := clientset.AutoscalingV2().HorizontalPodAutoscalers(opts.Namespace).Create(ctx, hpa, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create hpa resource: %v", err)
+	name, selector, err := parseMetricName(rest[0])
+	if err != nil {
+		return HPAMetric{}, fmt.Errorf("invalid metric in '%s': %v", input, err)
+	}
+	metric.Name = name
+	metric.Selector = selector
+
+	target, err := resource.ParseQuantity(rest[1])
+	if err != nil || target.Sign() <= 0 {
+		return HPAMetric{}, fmt.Errorf("invalid target '%s' in '%s', expected a positive quantity", rest[1], input)
+	}
+	metric.Target = target
+
+	if len(rest) == 3 {
+		metric.TargetType = strings.ToLower(rest[2])
+		if metric.TargetType != HPATargetValue && metric.TargetType != HPATargetAverageValue {
+			return HPAMetric{}, fmt.Errorf("invalid target type '%s' in '%s', expected value or averagevalue", rest[2], input)
 		}
-		fmt.Println("hpa resource successfully updated")
-	} else {
-		fmt.Println("hpa resource successfully created")
 	}
+	return metric, nil
+}
 
-	return nil
+func parseMetricName(input string) (string, *metav1.LabelSelector, error) {
+	name, selector, found := strings.Cut(input, "{")
+	if helpers.IsBlank(name) {
+		return "", nil, fmt.Errorf("metric name is empty")
+	}
+	if !found {
+		return name, nil, nil
+	}
+	if !strings.HasSuffix(selector, "}") {
+		return "", nil, fmt.Errorf("selector of '%s' is not closed by '}'", name)
+	}
+
+	// 逗号用于分隔多个指标，选择器中的多个条件改用 & 分隔
+	labelSelector, err := metav1.ParseToLabelSelector(strings.ReplaceAll(strings.TrimSuffix(selector, "}"), "&", ","))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse selector of '%s': %v", name, err)
+	}
+	return name, labelSelector, nil
 }
 
-func DeleteHPA(clientset *kubernetes.Clientset, ctx context.Context, opts HPAOptions) error {
-	err := clientset.AutoscalingV2().HorizontalPodAutoscalers(opts.Namespace).Delete(ctx, opts.Name, metav1.DeleteOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		return fmt.Errorf("failed to delete hpa resource: %v", err)
+// 格式为 type:value:period，如 pods:4:60s 或 percent:100:15s
+func ParseHPAScalingPolicy(input string) (HPAScalingPolicy, error) {
+	parts := strings.Split(input, ":")
+	if len(parts) != 3 {
+		return HPAScalingPolicy{}, fmt.Errorf("invalid format for scaling policy, expected 'type:value:period', got '%s'", input)
 	}
-	if apierrors.IsNotFound(err) {
-		fmt.Printf("hpa resource %s in namespace %s not found, no action taken\n", opts.Name, opts.Namespace)
-	} else {
-		fmt.Printf("hpa resource %s in namespace %s successfully deleted\n", opts.Name, opts.Namespace)
+
+	policy := HPAScalingPolicy{Type: strings.ToLower(parts[0])}
+	if policy.Type != HPAPolicyPods && policy.Type != HPAPolicyPercent {
+		return HPAScalingPolicy{}, fmt.Errorf("unsupported scaling policy type '%s' in '%s', expected pods or percent", parts[0], input)
 	}
-	return nil
+
+	value, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil || value < 1 {
+		return HPAScalingPolicy{}, fmt.Errorf("invalid value '%s' in '%s', expected a positive integer", parts[1], input)
+	}
+	policy.Value = int32(value)
+
+	period, err := time.ParseDuration(parts[2])
+	if err != nil || period < time.Second || period > 30*time.Minute || period%time.Second != 0 {
+		return HPAScalingPolicy{}, fmt.Errorf("invalid period '%s' in '%s', expected whole seconds between 1s and 30m", parts[2], input)
+	}
+	policy.Period = period
+	return policy, nil
+}
+
+func DeleteHPA(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace), name, namespace, "hpa", quiet)
 }
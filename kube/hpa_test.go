@@ -0,0 +1,233 @@
+package kube
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseHPAMetric(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    HPAMetric
+		wantErr bool
+	}{
+		{
+			input: "pods:http_requests_per_second:100",
+			want: HPAMetric{
+				Type:       HPAMetricPods,
+				Name:       "http_requests_per_second",
+				TargetType: HPATargetAverageValue,
+				Target:     resource.MustParse("100"),
+			},
+		},
+		{
+			input: "Pods:packets:1k",
+			want: HPAMetric{
+				Type:       HPAMetricPods,
+				Name:       "packets",
+				TargetType: HPATargetAverageValue,
+				Target:     resource.MustParse("1k"),
+			},
+		},
+		{
+			input: "object:networking.k8s.io/v1/Ingress/main-route:requests_per_second:2k",
+			want: HPAMetric{
+				Type: HPAMetricObject,
+				Name: "requests_per_second",
+				Object: autoscalingv2.CrossVersionObjectReference{
+					APIVersion: "networking.k8s.io/v1",
+					Kind:       "Ingress",
+					Name:       "main-route",
+				},
+				TargetType: HPATargetValue,
+				Target:     resource.MustParse("2k"),
+			},
+		},
+		{
+			input: "object:v1/Service/hellogo:requests:500m:AverageValue",
+			want: HPAMetric{
+				Type: HPAMetricObject,
+				Name: "requests",
+				Object: autoscalingv2.CrossVersionObjectReference{
+					APIVersion: "v1",
+					Kind:       "Service",
+					Name:       "hellogo",
+				},
+				TargetType: HPATargetAverageValue,
+				Target:     resource.MustParse("500m"),
+			},
+		},
+		{
+			input: "external:queue_length{queue=orders&env=prod}:30",
+			want: HPAMetric{
+				Type: HPAMetricExternal,
+				Name: "queue_length",
+				Selector: &metav1.LabelSelector{
+					MatchLabels:      map[string]string{"queue": "orders", "env": "prod"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{},
+				},
+				TargetType: HPATargetAverageValue,
+				Target:     resource.MustParse("30"),
+			},
+		},
+		{
+			input: "external:queue_length{queue in (a,b)}:30:value",
+			want: HPAMetric{
+				Type: HPAMetricExternal,
+				Name: "queue_length",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{},
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "queue", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+					},
+				},
+				TargetType: HPATargetValue,
+				Target:     resource.MustParse("30"),
+			},
+		},
+		{input: "pods:requests", wantErr: true},
+		{input: "pods:requests:100:value", wantErr: true},
+		{input: "object:Service/hellogo:requests:100", wantErr: true},
+		{input: "object:v1/Service/:requests:100", wantErr: true},
+		{input: "object:v1/Service/hellogo:requests", wantErr: true},
+		{input: "external:queue_length:30:value:extra", wantErr: true},
+		{input: "resource:cpu:100", wantErr: true},
+		{input: "pods::100", wantErr: true},
+		{input: "pods:requests:abc", wantErr: true},
+		{input: "pods:requests:0", wantErr: true},
+		{input: "pods:requests:-1", wantErr: true},
+		{input: "external:queue_length:30:utilization", wantErr: true},
+		{input: "external:queue_length{queue=orders:30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseHPAMetric(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseHPAMetric() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHPAMetric() error = %v", err)
+			}
+			// Quantity 内部带有缓存，单独比较数值
+			if got.Target.Cmp(tt.want.Target) != 0 {
+				t.Errorf("ParseHPAMetric() target = %s, want %s", got.Target.String(), tt.want.Target.String())
+			}
+			got.Target, tt.want.Target = resource.Quantity{}, resource.Quantity{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHPAMetric() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMetricName(t *testing.T) {
+	tests := []struct {
+		input        string
+		wantName     string
+		wantSelector *metav1.LabelSelector
+		wantErr      bool
+	}{
+		{input: "requests", wantName: "requests"},
+		{
+			input:    "requests{app=hellogo}",
+			wantName: "requests",
+			wantSelector: &metav1.LabelSelector{
+				MatchLabels:      map[string]string{"app": "hellogo"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{},
+			},
+		},
+		{
+			input:    "requests{a=b&c=d}",
+			wantName: "requests",
+			wantSelector: &metav1.LabelSelector{
+				MatchLabels:      map[string]string{"a": "b", "c": "d"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{},
+			},
+		},
+		{
+			input:    "requests{a=b&c notin (d,e)}",
+			wantName: "requests",
+			wantSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"a": "b"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "c", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"d", "e"}},
+				},
+			},
+		},
+		{input: "", wantErr: true},
+		{input: "{a=b}", wantErr: true},
+		{input: "requests{a=b", wantErr: true},
+		{input: "requests{a=b=c}", wantErr: true},
+		{input: "requests{a!=b}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, selector, err := parseMetricName(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseMetricName() = %s, %+v, want error", name, selector)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMetricName() error = %v", err)
+			}
+			if name != tt.wantName {
+				t.Errorf("parseMetricName() name = %s, want %s", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(selector, tt.wantSelector) {
+				t.Errorf("parseMetricName() selector = %+v, want %+v", selector, tt.wantSelector)
+			}
+		})
+	}
+}
+
+func TestParseHPAScalingPolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    HPAScalingPolicy
+		wantErr bool
+	}{
+		{input: "pods:4:60s", want: HPAScalingPolicy{Type: HPAPolicyPods, Value: 4, Period: time.Minute}},
+		{input: "Percent:100:15s", want: HPAScalingPolicy{Type: HPAPolicyPercent, Value: 100, Period: 15 * time.Second}},
+		{input: "pods:1:30m", want: HPAScalingPolicy{Type: HPAPolicyPods, Value: 1, Period: 30 * time.Minute}},
+		{input: "pods:4", wantErr: true},
+		{input: "pods:4:60s:extra", wantErr: true},
+		{input: "replicas:4:60s", wantErr: true},
+		{input: "pods:0:60s", wantErr: true},
+		{input: "pods:-1:60s", wantErr: true},
+		{input: "pods:abc:60s", wantErr: true},
+		{input: "pods:4:abc", wantErr: true},
+		{input: "pods:4:500ms", wantErr: true},
+		{input: "pods:4:1500ms", wantErr: true},
+		{input: "pods:4:31m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseHPAScalingPolicy(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseHPAScalingPolicy() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHPAScalingPolicy() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHPAScalingPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
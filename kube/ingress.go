@@ -2,145 +2,285 @@ package kube
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
 	"fmt"
-	"math/big"
-	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/guobinqiu/appdeployer/helpers"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
+// Ingress 类型为 nginx 时才会添加 nginx 专用的注解，金丝雀发布依赖 nginx
+const IngressClassNginx = "nginx"
+
 type IngressOptions struct {
-	Name            string
-	Namespace       string
-	Host            string
-	TLS             bool
-	SelfSigned      bool
-	SelfSignedYears int
-	CrtPath         string
-	KeyPath         string
+	Name              string
+	Namespace         string
+	Host              string
+	ServicePort       string // 流量转发到的 Service 端口名称，默认为 http
+	ClassName         string
+	Annotations       map[string]string // 额外的注解，覆盖同名的默认注解
+	Rules             []IngressRule     // 为空时只有 Host 的 / 一条规则
+	TLS               bool
+	Issuer            string        // cert-manager 的 issuer，设置后由 cert-manager 签发证书
+	IssuerKind        string        // ClusterIssuer 或 Issuer，不区分大小写
+	IssuerTimeout     time.Duration // 等待证书签发完成的时间
+	SelfSigned        bool
+	SelfSignedYears   int
+	SelfSignedKeyType string // rsa、ecdsa 或 ed25519
+	CADir             string // 保存自签名CA的目录，多次发布复用同一个CA
+	CrtPath           string
+	KeyPath           string
+	MinValidity       time.Duration // 证书文件剩余有效期的下限
+}
+
+type IngressRule struct {
+	Host  string
+	Paths []IngressPath
+}
+
+type IngressPath struct {
+	Path        string
+	PathType    string // Prefix、Exact 或 ImplementationSpecific，不区分大小写
+	ServicePort string
+}
+
+func CreateOrUpdateIngress(clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, ctx context.Context, opts IngressOptions) error {
+	if opts.TLS {
+		if err := CreateOrUpdateTLS(clientset, dynamicClient, ctx, opts); err != nil {
+			return err
+		}
+	}
+
+	ingress, err := NewIngress(opts)
+	if err != nil {
+		return err
+	}
+	_, err = apply(ctx, clientset.NetworkingV1().Ingresses(opts.Namespace), ingress, "ingress")
+	return err
 }
 
-func CreateOrUpdateIngress(clientset *kubernetes.Clientset, ctx context.Context, opts IngressOptions) error {
-	ingressClass := "nginx"
-	pathType := networkingv1.PathTypePrefix
+func NewIngress(opts IngressOptions) (*networkingv1.Ingress, error) {
+	className := opts.ClassName
+	if className == "" {
+		className = IngressClassNginx
+	}
+
+	annotations := map[string]string{}
+	if className == IngressClassNginx {
+		annotations["nginx.ingress.kubernetes.io/ssl-passthrough"] = "false"
+		annotations["nginx.ingress.kubernetes.io/backend-protocol"] = "HTTP"
+		if opts.TLS {
+			annotations["nginx.ingress.kubernetes.io/force-ssl-redirect"] = "true"
+		}
+	}
+	for key, value := range opts.Annotations {
+		annotations[key] = value
+	}
 
 	ingress := &networkingv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      opts.Name,
-			Namespace: opts.Namespace,
-			Annotations: map[string]string{
-				"nginx.ingress.kubernetes.io/force-ssl-redirect": "true",
-				"nginx.ingress.kubernetes.io/ssl-passthrough":    "false",
-				"nginx.ingress.kubernetes.io/backend-protocol":   "HTTP",
-			},
+			Name:        opts.Name,
+			Namespace:   opts.Namespace,
+			Annotations: annotations,
 		},
 		Spec: networkingv1.IngressSpec{
-			IngressClassName: &ingressClass,
-			Rules: []networkingv1.IngressRule{
-				{
-					Host: opts.Host,
-					IngressRuleValue: networkingv1.IngressRuleValue{
-						HTTP: &networkingv1.HTTPIngressRuleValue{
-							Paths: []networkingv1.HTTPIngressPath{
-								{
-									Path:     "/",
-									PathType: &pathType,
-									Backend: networkingv1.IngressBackend{
-										Service: &networkingv1.IngressServiceBackend{
-											Name: opts.Name,
-											Port: networkingv1.ServiceBackendPort{
-												Name: "http",
-											},
-										},
-									},
-								},
-							},
+			IngressClassName: &className,
+		},
+	}
+
+	for _, rule := range IngressRules(opts) {
+		var paths []networkingv1.HTTPIngressPath
+		for _, path := range rule.Paths {
+			pathType, err := parsePathType(path.PathType)
+			if err != nil {
+				return nil, err
+			}
+			servicePort := path.ServicePort
+			if servicePort == "" {
+				servicePort = opts.ServicePort
+			}
+			if servicePort == "" {
+				servicePort = httpPortName
+			}
+			paths = append(paths, networkingv1.HTTPIngressPath{
+				Path:     path.Path,
+				PathType: &pathType,
+				Backend: networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{
+						Name: opts.Name,
+						Port: networkingv1.ServiceBackendPort{
+							Name: servicePort,
 						},
 					},
 				},
+			})
+		}
+		ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{
+			Host: rule.Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: paths,
+				},
 			},
-		},
+		})
 	}
 
 	if opts.TLS {
-		if err := CreateOrUpdateTlsSecret(clientset, ctx, opts); err != nil {
-			return err
-		}
-
 		ingress.Spec.TLS = []networkingv1.IngressTLS{
 			{
-				Hosts: []string{
-					opts.Host,
-				},
+				Hosts:      IngressHosts(opts),
 				SecretName: "tls-" + opts.Name,
 			},
 		}
 	}
 
-	if _, err := clientset.NetworkingV1().Ingresses(opts.Namespace).Create(ctx, ingress, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create ingress resource: %v", err)
+	return ingress, nil
+}
+
+// 没有配置规则时，Host 的 / 路径转发到 Service
+func IngressRules(opts IngressOptions) []IngressRule {
+	if len(opts.Rules) > 0 {
+		return opts.Rules
+	}
+	return []IngressRule{
+		{
+			Host: opts.Host,
+			Paths: []IngressPath{
+				{Path: "/"},
+			},
+		},
+	}
+}
+
+// 所有规则中的 host，去掉重复的
+func IngressHosts(opts IngressOptions) []string {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, rule := range IngressRules(opts) {
+		if !seen[rule.Host] {
+			seen[rule.Host] = true
+			hosts = append(hosts, rule.Host)
 		}
-		fmt.Println("ingress resource successfully updated")
-	} else {
-		fmt.Println("ingress resource successfully created")
 	}
+	return hosts
+}
 
-	return nil
+// 解析 [host]/path[:pathType[:servicePort]]，省略 host 时使用 defaultHost，同一个 host 的路径合并为一条规则
+func ParseIngressRules(inputs []string, defaultHost string) ([]IngressRule, error) {
+	var rules []IngressRule
+	index := map[string]int{}
+	for _, input := range inputs {
+		slash := strings.Index(input, "/")
+		if slash < 0 {
+			return nil, fmt.Errorf("invalid format for ingress rule, expected '[host]/path[:pathType[:servicePort]]', got '%s'", input)
+		}
+		host := input[:slash]
+		if host == "" {
+			host = defaultHost
+		}
+		parts := strings.Split(input[slash:], ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid format for ingress rule, expected '[host]/path[:pathType[:servicePort]]', got '%s'", input)
+		}
+
+		path := IngressPath{Path: parts[0]}
+		if len(parts) > 1 {
+			if _, err := parsePathType(parts[1]); err != nil {
+				return nil, err
+			}
+			path.PathType = parts[1]
+		}
+		if len(parts) > 2 {
+			path.ServicePort = parts[2]
+		}
+
+		i, ok := index[host]
+		if !ok {
+			i = len(rules)
+			index[host] = i
+			rules = append(rules, IngressRule{Host: host})
+		}
+		rules[i].Paths = append(rules[i].Paths, path)
+	}
+	return rules, nil
+}
+
+func parsePathType(pathType string) (networkingv1.PathType, error) {
+	switch strings.ToLower(pathType) {
+	case "", "prefix":
+		return networkingv1.PathTypePrefix, nil
+	case "exact":
+		return networkingv1.PathTypeExact, nil
+	case "implementationspecific":
+		return networkingv1.PathTypeImplementationSpecific, nil
+	default:
+		return "", fmt.Errorf("unsupported path type: '%s'", pathType)
+	}
+}
+
+func DeleteIngress(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.NetworkingV1().Ingresses(namespace), name, namespace, "ingress", quiet)
 }
 
 func CreateOrUpdateTlsSecret(clientset *kubernetes.Clientset, ctx context.Context, opts IngressOptions) error {
-	var tlsKeyBytes, tlsCertBytes []byte
+	tlsSecret, err := NewTlsSecret(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = apply(ctx, clientset.CoreV1().Secrets(opts.Namespace), tlsSecret, "tls secret")
+	return err
+}
+
+func NewTlsSecret(opts IngressOptions) (*corev1.Secret, error) {
+	var tlsKeyBytes, tlsCertBytes, caCertBytes []byte
 
 	if opts.SelfSigned {
-		cm := &CertificateManager{}
+		cm := &CertificateManager{
+			KeyType: opts.SelfSignedKeyType,
+			CADir:   opts.CADir,
+		}
 
-		// 创建CA证书和私钥
-		caCert, caPrivateKey, err := cm.CreateCACertificate(int(opts.SelfSignedYears))
+		// 读取保存的CA，第一次使用时创建
+		caCert, caPrivateKey, err := cm.LoadOrCreateCA(DefaultCACommonName, DefaultCAYears)
 		if err != nil {
-			return fmt.Errorf("failed to create ca certificate: %v", err)
+			return nil, fmt.Errorf("failed to load ca certificate: %v", err)
 		}
 
-		// 创建服务器证书和私钥
-		serverCertBytes, serverPrivateKey, err := cm.CreateServerCertificate(caCert, caPrivateKey, opts.Host)
+		// 为所有 host 创建服务器证书和私钥
+		serverCertBytes, serverPrivateKey, err := cm.CreateServerCertificate(caCert, caPrivateKey, IngressHosts(opts), opts.SelfSignedYears)
 		if err != nil {
-			return fmt.Errorf("failed to create server certificate: %v", err)
+			return nil, fmt.Errorf("failed to create server certificate: %v", err)
 		}
 
-		// 将证书和私钥保存到文件（PEM格式）
-		tlsKeyBytes = cm.EncodePrivateKeyToPEM(serverPrivateKey)
-		tlsCertBytes = cm.EncodeCertificateToPEM(serverCertBytes)
-	} else {
-		tlsCert, err := os.ReadFile(helpers.ExpandUser(filepath.Clean(opts.CrtPath)))
+		// 将证书和私钥转换为PEM格式
+		tlsKeyBytes, err = cm.EncodePrivateKeyToPEM(serverPrivateKey)
 		if err != nil {
-			return fmt.Errorf("failed to read certificate file: %v", err)
+			return nil, err
 		}
-
-		tlsKey, err := os.ReadFile(helpers.ExpandUser(filepath.Clean(opts.KeyPath)))
+		tlsCertBytes = cm.EncodeCertificateToPEM(serverCertBytes)
+		caCertBytes = cm.EncodeCertificateToPEM(caCert.Raw)
+	} else {
+		var err error
+		tlsCertBytes, tlsKeyBytes, err = LoadTLSFiles(opts)
 		if err != nil {
-			return fmt.Errorf("failed to read key file: %v", err)
+			return nil, err
 		}
-
-		tlsKeyBytes = tlsCert
-		tlsCertBytes = tlsKey
 	}
 
-	tlsSecret := &corev1.Secret{
+	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "tls-" + opts.Name,
+			Name:      "tls-" + opts.Name,
+			Namespace: opts.Namespace,
 		},
 		Type: corev1.SecretTypeTLS,
 		Data: map[string][]byte{
@@ -148,116 +288,82 @@ func CreateOrUpdateTlsSecret(clientset *kubernetes.Clientset, ctx context.Contex
 			corev1.TLSCertKey:       tlsCertBytes,
 		},
 	}
-
-	if _, err := clientset.CoreV1().Secrets(opts.Namespace).Create(ctx, tlsSecret, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create tls secret resource: %v", err)
-		}
-		fmt.Println("tls secret resource successfully updated")
-	} else {
-		fmt.Println("tls secret resource successfully created")
+	// 客户端可以从 ca.crt 取得自签名的CA
+	if caCertBytes != nil {
+		secret.Data["ca.crt"] = caCertBytes
 	}
-
-	return nil
+	return secret, nil
 }
 
-type CertificateManager struct{}
-
-// 创建一个CA
-func (cm *CertificateManager) CreateCACertificate(years int) (*x509.Certificate, *rsa.PrivateKey, error) {
-	// 生成私钥
-	caPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+// 读取证书链和私钥文件，并检查私钥与证书是否匹配、证书是否覆盖所有 host 以及有效期
+func LoadTLSFiles(opts IngressOptions) ([]byte, []byte, error) {
+	crtPath := helpers.ExpandUser(filepath.Clean(opts.CrtPath))
+	tlsCert, err := os.ReadFile(crtPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate CA private key: %v", err)
+		return nil, nil, fmt.Errorf("failed to read certificate file: %v", err)
 	}
 
-	// 设置CA证书模板
-	caTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Organization:       []string{""},
-			OrganizationalUnit: []string{""},
-			Country:            []string{""},
-			Province:           []string{""},
-			Locality:           []string{""},
-			CommonName:         "",
-		},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(years, 0, 0), // 有效期
-		IsCA:                  true,
-		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
-		BasicConstraintsValid: true,
-		MaxPathLen:            0,
-		MaxPathLenZero:        true,
-	}
-
-	// 根据模板创建自签名的CA证书
-	caBytes, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caPrivateKey.PublicKey, caPrivateKey)
+	tlsKey, err := os.ReadFile(helpers.ExpandUser(filepath.Clean(opts.KeyPath)))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create CA certificate: %v", err)
+		return nil, nil, fmt.Errorf("failed to read key file: %v", err)
 	}
 
-	// 将CA证书解析为结构体
-	caCert, err := x509.ParseCertificate(caBytes)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse created CA certificate: %v", err)
+	if problems := validateCertificate(tlsCert, tlsKey, IngressHosts(opts), opts.MinValidity, time.Now()); len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid certificate %s:\n  - %s", crtPath, strings.Join(problems, "\n  - "))
 	}
-
-	return caCert, caPrivateKey, nil
+	return tlsCert, tlsKey, nil
 }
 
-// 使用CA签发服务器证书
-func (cm *CertificateManager) CreateServerCertificate(caCert *x509.Certificate, caPrivateKey *rsa.PrivateKey, serverName string) ([]byte, *rsa.PrivateKey, error) {
-	// 生成服务器私钥
-	serverPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+// 返回证书的所有问题，没有问题时返回空
+func validateCertificate(certPEM, keyPEM []byte, hosts []string, minValidity time.Duration, now time.Time) []string {
+	chain, err := ParseCertificatesPEM(certPEM)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate server private key: %v", err)
+		return []string{fmt.Sprintf("%v, the certificate and key files may be swapped", err)}
 	}
 
-	// 设置服务器证书模板
-	serverTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(2),
-		Subject: pkix.Name{
-			Organization: []string{""},
-			Country:      []string{""},
-			Province:     []string{""},
-			Locality:     []string{""},
-			CommonName:   serverName,
-		},
-		DNSNames:    []string{serverName},
-		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().AddDate(1, 0, 0), // 有效期1年
-
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	var problems []string
+	// 证书链中的第一个证书是服务器证书
+	leaf := chain[0]
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		problems = append(problems, fmt.Sprintf("private key does not match the certificate: %v", err))
+	}
 
-		Issuer: caCert.Subject,
+	for _, host := range hosts {
+		if err := leaf.VerifyHostname(host); err != nil {
+			problems = append(problems, fmt.Sprintf("host %s is not covered by the subject alternative names of the certificate (%s)", host, certificateNames(leaf)))
+		}
 	}
 
-	// 根据CA签发服务器证书
-	serverBytes, err := x509.CreateCertificate(rand.Reader, &serverTemplate, caCert, &serverPrivateKey.PublicKey, caPrivateKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create server certificate: %v", err)
+	switch {
+	case now.Before(leaf.NotBefore):
+		problems = append(problems, fmt.Sprintf("certificate is not valid until %s", leaf.NotBefore.Format(time.RFC3339)))
+	case now.After(leaf.NotAfter):
+		problems = append(problems, fmt.Sprintf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339)))
+	case now.Add(minValidity).After(leaf.NotAfter):
+		problems = append(problems, fmt.Sprintf("certificate expires at %s, within %s", leaf.NotAfter.Format(time.RFC3339), minValidity))
 	}
 
-	return serverBytes, serverPrivateKey, nil
+	for _, cert := range chain[1:] {
+		if now.After(cert.NotAfter) {
+			problems = append(problems, fmt.Sprintf("intermediate certificate %s expired at %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339)))
+		}
+	}
+	return problems
 }
 
-// 将私钥转换为PEM格式
-func (cm *CertificateManager) EncodePrivateKeyToPEM(privateKey *rsa.PrivateKey) []byte {
-	pemBlock := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+// 证书的 SAN，只有 CommonName 的证书不被浏览器接受
+func certificateNames(cert *x509.Certificate) string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
 	}
-	return pem.EncodeToMemory(pemBlock)
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
 }
 
-// 将证书转换为PEM格式
-func (cm *CertificateManager) EncodeCertificateToPEM(cert []byte) []byte {
-	pemBlock := &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: cert,
-	}
-	return pem.EncodeToMemory(pemBlock)
+func DeleteTlsSecret(clientset *kubernetes.Clientset, ctx context.Context, opts IngressOptions) error {
+	return remove(ctx, clientset.CoreV1().Secrets(opts.Namespace), "tls-"+opts.Name, opts.Namespace, "tls secret", false)
 }
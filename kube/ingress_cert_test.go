@@ -0,0 +1,146 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateCertificate(t *testing.T) {
+	cm := &CertificateManager{KeyType: KeyTypeECDSA}
+	caCert, caKey, err := cm.CreateCACertificate(DefaultCACommonName, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(hosts ...string) ([]byte, []byte) {
+		der, key, err := cm.CreateServerCertificate(caCert, caKey, hosts, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyPEM, err := cm.EncodePrivateKeyToPEM(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cm.EncodeCertificateToPEM(der), keyPEM
+	}
+
+	certPEM, keyPEM := issue("hellogo.com", "*.example.com", "10.0.0.1")
+	_, otherKeyPEM := issue("hellogo.com")
+	chain, err := ParseCertificatesPEM(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := chain[0]
+
+	// 有效期为 0 年的 CA 签发后立即过期，作为过期的中间证书
+	expiredCA, _, err := cm.CreateCACertificate("expired CA", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredChainPEM := append(append([]byte{}, certPEM...), cm.EncodeCertificateToPEM(expiredCA.Raw)...)
+
+	now := time.Now()
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		certPEM     []byte
+		keyPEM      []byte
+		hosts       []string
+		minValidity time.Duration
+		now         time.Time
+		want        []string // 每个问题中应包含的内容，按顺序
+	}{
+		{
+			name:        "valid",
+			certPEM:     certPEM,
+			keyPEM:      keyPEM,
+			hosts:       []string{"hellogo.com", "api.example.com", "10.0.0.1"},
+			minValidity: 30 * day,
+			now:         now,
+		},
+		{
+			name:    "cert and key swapped",
+			certPEM: keyPEM,
+			keyPEM:  certPEM,
+			hosts:   []string{"hellogo.com"},
+			now:     now,
+			want:    []string{"swapped"},
+		},
+		{
+			name:    "key of another certificate",
+			certPEM: certPEM,
+			keyPEM:  otherKeyPEM,
+			hosts:   []string{"hellogo.com"},
+			now:     now,
+			want:    []string{"private key does not match"},
+		},
+		{
+			name:    "hosts not covered",
+			certPEM: certPEM,
+			keyPEM:  keyPEM,
+			hosts:   []string{"hellogo.com", "other.com", "a.b.example.com", "10.0.0.2"},
+			now:     now,
+			want:    []string{"host other.com", "host a.b.example.com", "host 10.0.0.2"},
+		},
+		{
+			name:    "not valid yet",
+			certPEM: certPEM,
+			keyPEM:  keyPEM,
+			now:     leaf.NotBefore.Add(-time.Hour),
+			want:    []string{"not valid until"},
+		},
+		{
+			name:    "expired",
+			certPEM: certPEM,
+			keyPEM:  keyPEM,
+			now:     leaf.NotAfter.Add(time.Hour),
+			want:    []string{"expired at"},
+		},
+		{
+			name:        "expires within min validity",
+			certPEM:     certPEM,
+			keyPEM:      keyPEM,
+			minValidity: 30 * day,
+			now:         leaf.NotAfter.Add(-10 * day),
+			want:        []string{"within 720h0m0s"},
+		},
+		{
+			name:        "expires after min validity",
+			certPEM:     certPEM,
+			keyPEM:      keyPEM,
+			minValidity: 30 * day,
+			now:         leaf.NotAfter.Add(-31 * day),
+		},
+		{
+			name:    "expired intermediate",
+			certPEM: expiredChainPEM,
+			keyPEM:  keyPEM,
+			hosts:   []string{"hellogo.com"},
+			now:     now.Add(time.Minute),
+			want:    []string{"intermediate certificate expired CA expired"},
+		},
+		{
+			name:    "several problems",
+			certPEM: certPEM,
+			keyPEM:  otherKeyPEM,
+			hosts:   []string{"other.com"},
+			now:     leaf.NotAfter.Add(time.Hour),
+			want:    []string{"private key does not match", "host other.com", "expired at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validateCertificate(tt.certPEM, tt.keyPEM, tt.hosts, tt.minValidity, tt.now)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("validateCertificate() = %q, want %d problems", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
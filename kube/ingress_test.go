@@ -0,0 +1,137 @@
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestParseIngressRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputs      []string
+		defaultHost string
+		want        []IngressRule
+		wantErr     bool
+	}{
+		{
+			name:        "path only uses the default host",
+			inputs:      []string{"/"},
+			defaultHost: "hellogo.com",
+			want:        []IngressRule{{Host: "hellogo.com", Paths: []IngressPath{{Path: "/"}}}},
+		},
+		{
+			name:   "empty default host",
+			inputs: []string{"/v1"},
+			want:   []IngressRule{{Host: "", Paths: []IngressPath{{Path: "/v1"}}}},
+		},
+		{
+			name:        "host, path type and service port",
+			inputs:      []string{"api.example.com/v1:exact:metrics"},
+			defaultHost: "hellogo.com",
+			want:        []IngressRule{{Host: "api.example.com", Paths: []IngressPath{{Path: "/v1", PathType: "exact", ServicePort: "metrics"}}}},
+		},
+		{
+			name:        "empty path type keeps the default",
+			inputs:      []string{"/grpc::grpc"},
+			defaultHost: "hellogo.com",
+			want:        []IngressRule{{Host: "hellogo.com", Paths: []IngressPath{{Path: "/grpc", ServicePort: "grpc"}}}},
+		},
+		{
+			name:        "paths of the same host are merged in order",
+			inputs:      []string{"/v1:prefix", "api.example.com/v2", "hellogo.com/healthz:exact"},
+			defaultHost: "hellogo.com",
+			want: []IngressRule{
+				{Host: "hellogo.com", Paths: []IngressPath{{Path: "/v1", PathType: "prefix"}, {Path: "/healthz", PathType: "exact"}}},
+				{Host: "api.example.com", Paths: []IngressPath{{Path: "/v2"}}},
+			},
+		},
+		{
+			name:        "slashes after the first belong to the path",
+			inputs:      []string{"api.example.com/v1/users/"},
+			defaultHost: "hellogo.com",
+			want:        []IngressRule{{Host: "api.example.com", Paths: []IngressPath{{Path: "/v1/users/"}}}},
+		},
+		{
+			name:        "no rules",
+			defaultHost: "hellogo.com",
+		},
+		{
+			name:        "missing path",
+			inputs:      []string{"api.example.com"},
+			defaultHost: "hellogo.com",
+			wantErr:     true,
+		},
+		{
+			name:        "colon in path is read as path type",
+			inputs:      []string{"/files/a:b"},
+			defaultHost: "hellogo.com",
+			wantErr:     true,
+		},
+		{
+			name:        "unsupported path type",
+			inputs:      []string{"/v1:regex"},
+			defaultHost: "hellogo.com",
+			wantErr:     true,
+		},
+		{
+			name:        "too many fields",
+			inputs:      []string{"/v1:prefix:http:extra"},
+			defaultHost: "hellogo.com",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIngressRules(tt.inputs, tt.defaultHost)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseIngressRules() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseIngressRules() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseIngressRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePathType(t *testing.T) {
+	tests := []struct {
+		pathType string
+		want     networkingv1.PathType
+		wantErr  bool
+	}{
+		{pathType: "", want: networkingv1.PathTypePrefix},
+		{pathType: "prefix", want: networkingv1.PathTypePrefix},
+		{pathType: "Prefix", want: networkingv1.PathTypePrefix},
+		{pathType: "EXACT", want: networkingv1.PathTypeExact},
+		{pathType: "implementationspecific", want: networkingv1.PathTypeImplementationSpecific},
+		{pathType: "regex", wantErr: true},
+		{pathType: " prefix", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pathType, func(t *testing.T) {
+			got, err := parsePathType(tt.pathType)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsePathType() = %s, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePathType() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parsePathType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	WorkloadJob     = "job"
+	WorkloadCronJob = "cronjob"
+)
+
+// 等待旧 Job 及其 pod 被删除的时间
+const jobDeleteTimeout = 2 * time.Minute
+
+var errJobFailed = errors.New("job failed")
+
+type JobOptions struct {
+	Deployment   DeploymentOptions // 复用 pod 模板相关的选项
+	BackoffLimit int32
+}
+
+type CronJobOptions struct {
+	Job                        JobOptions
+	Schedule                   string
+	ConcurrencyPolicy          string // Allow、Forbid 或 Replace，不区分大小写
+	SuccessfulJobsHistoryLimit int32
+	FailedJobsHistoryLimit     int32
+}
+
+// Job 的 pod 模板不可修改，因此先删除旧的 Job 再创建
+func CreateOrReplaceJob(clientset *kubernetes.Clientset, ctx context.Context, opts JobOptions) error {
+	job, err := NewJob(opts)
+	if err != nil {
+		return err
+	}
+
+	name := opts.Deployment.Name
+	namespace := opts.Deployment.Namespace
+	if err := DeleteJob(clientset, ctx, name, namespace, true); err != nil {
+		return err
+	}
+	err = wait.PollUntilContextTimeout(ctx, time.Second, jobDeleteTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for old job resource to be deleted: %v", err)
+	}
+
+	_, err = apply(ctx, clientset.BatchV1().Jobs(namespace), job, "job")
+	return err
+}
+
+func NewJob(opts JobOptions) (*batchv1.Job, error) {
+	spec, err := newJobSpec(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Deployment.Name,
+			Namespace: opts.Deployment.Namespace,
+		},
+		Spec: spec,
+	}, nil
+}
+
+func CreateOrUpdateCronJob(clientset *kubernetes.Clientset, ctx context.Context, opts CronJobOptions) error {
+	cronJob, err := NewCronJob(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = apply(ctx, clientset.BatchV1().CronJobs(opts.Job.Deployment.Namespace), cronJob, "cronjob")
+	return err
+}
+
+func NewCronJob(opts CronJobOptions) (*batchv1.CronJob, error) {
+	spec, err := newJobSpec(opts.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrencyPolicy, err := parseConcurrencyPolicy(opts.ConcurrencyPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Job.Deployment.Name,
+			Namespace: opts.Job.Deployment.Namespace,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   opts.Schedule,
+			ConcurrencyPolicy:          concurrencyPolicy,
+			SuccessfulJobsHistoryLimit: &opts.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     &opts.FailedJobsHistoryLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: podLabels(opts.Job.Deployment.Name, ""),
+				},
+				Spec: spec,
+			},
+		},
+	}, nil
+}
+
+// 失败的 pod 保留下来以便查看日志，由 Job 按 backoffLimit 重新创建
+func newJobSpec(opts JobOptions) (batchv1.JobSpec, error) {
+	template, err := NewPodTemplate(opts.Deployment)
+	if err != nil {
+		return batchv1.JobSpec{}, err
+	}
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	return batchv1.JobSpec{
+		BackoffLimit: &opts.BackoffLimit,
+		Template:     template,
+	}, nil
+}
+
+func parseConcurrencyPolicy(policy string) (batchv1.ConcurrencyPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "allow":
+		return batchv1.AllowConcurrent, nil
+	case "forbid":
+		return batchv1.ForbidConcurrent, nil
+	case "replace":
+		return batchv1.ReplaceConcurrent, nil
+	default:
+		return "", fmt.Errorf("unsupported concurrency policy: '%s'", policy)
+	}
+}
+
+func DeleteJob(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.BatchV1().Jobs(namespace), name, namespace, "job", quiet)
+}
+
+func DeleteCronJob(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.BatchV1().CronJobs(namespace), name, namespace, "cronjob", quiet)
+}
+
+func jobStatus(job *batchv1.Job) (string, bool, error) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return fmt.Sprintf("job completed, %d pods succeeded", job.Status.Succeeded), true, nil
+		case batchv1.JobFailed:
+			return fmt.Sprintf("%s: %s", cond.Reason, cond.Message), false, errJobFailed
+		}
+	}
+	return fmt.Sprintf("waiting for job to complete: %d active, %d succeeded, %d failed...", job.Status.Active, job.Status.Succeeded, job.Status.Failed), false, nil
+}
@@ -10,21 +10,48 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// 标记由 appdeployer 创建的命名空间，destroy 时才会删除
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "appdeployer"
+)
+
 func CreateOrUpdateNamespace(clientset *kubernetes.Clientset, ctx context.Context, namespace string) error {
-	ns := &corev1.Namespace{
+	ns := NewNamespace(namespace)
+
+	// 只给新建的命名空间打标签，已有的命名空间不据为己有
+	existing, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get namespace resource: %v", err)
+	}
+	if apierrors.IsNotFound(err) || existing.Labels[managedByLabel] == managedByValue {
+		ns.Labels = map[string]string{managedByLabel: managedByValue}
+	}
+
+	_, err = apply(ctx, clientset.CoreV1().Namespaces(), ns, "namespace")
+	return err
+}
+
+func NewNamespace(namespace string) *corev1.Namespace {
+	return &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: namespace,
 		},
 	}
+}
 
-	if _, err := clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create ingress resource: %v", err)
-		}
-		fmt.Println("namespace resource successfully updated")
-	} else {
-		fmt.Println("namespace resource successfully created")
+// 命名空间是否由 appdeployer 创建，不存在时返回 false
+func NamespaceManaged(clientset *kubernetes.Clientset, ctx context.Context, namespace string) (bool, error) {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
 	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get namespace resource: %v", err)
+	}
+	return ns.Labels[managedByLabel] == managedByValue, nil
+}
 
-	return nil
+func DeleteNamespace(clientset *kubernetes.Clientset, ctx context.Context, namespace string) error {
+	return remove(ctx, clientset.CoreV1().Namespaces(), namespace, "", "namespace", false)
 }
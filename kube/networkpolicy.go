@@ -0,0 +1,328 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	dnsNamespace       = "kube-system"
+	dnsPort            = 53
+)
+
+// 同一命名空间下可能有多个 app，所以只选择当前 app 的 pod，而不是整个命名空间
+type NetworkPolicyOptions struct {
+	Name              string
+	Namespace         string
+	Enabled           bool
+	IngressNamespaces []string       // ingress controller 或 gateway 所在的命名空间，允许访问 Ingress 转发到的端口
+	AllowApps         []string       // [namespace/]app，允许访问 app 所有端口，命名空间默认与 app 同名
+	AllowCIDRs        []string       // cidr[:port]，不带端口时允许访问 app 所有端口
+	Egress            bool           // 是否限制出站流量，限制后只能访问 DNS、同一 app 以及下面的目标
+	EgressApps        []string       // [namespace/]app，允许访问的其他 app 的所有端口
+	EgressCIDRs       []string       // cidr[:port]，不带端口时允许访问所有端口
+	Service           ServiceOptions // app 的端口从 Service 的 targetPort 中获得
+	Ingress           IngressOptions // 按规则中的 servicePort 找出 Ingress 转发到的端口
+}
+
+func CreateOrUpdateNetworkPolicies(clientset *kubernetes.Clientset, ctx context.Context, opts NetworkPolicyOptions) error {
+	policies, err := NewNetworkPolicies(opts)
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		if _, err := apply(ctx, clientset.NetworkingV1().NetworkPolicies(opts.Namespace), policy, "networkpolicy"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 默认拒绝的策略和放行的策略，pod 被多个策略选中时放行规则取并集
+func NewNetworkPolicies(opts NetworkPolicyOptions) ([]*networkingv1.NetworkPolicy, error) {
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if opts.Egress {
+		policyTypes = append(policyTypes, networkingv1.PolicyTypeEgress)
+	}
+
+	ingressRules, err := networkPolicyIngressRules(opts)
+	if err != nil {
+		return nil, err
+	}
+	egressRules, err := networkPolicyEgressRules(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	deny := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultDenyPolicyName(opts.Name),
+			Namespace: opts.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *appPodSelector(opts.Name),
+			PolicyTypes: policyTypes,
+		},
+	}
+	allow := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AllowPolicyName(opts.Name),
+			Namespace: opts.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *appPodSelector(opts.Name),
+			PolicyTypes: policyTypes,
+			Ingress:     ingressRules,
+			Egress:      egressRules,
+		},
+	}
+	return []*networkingv1.NetworkPolicy{deny, allow}, nil
+}
+
+func DefaultDenyPolicyName(name string) string {
+	return name + "-default-deny"
+}
+
+func AllowPolicyName(name string) string {
+	return name + "-allow"
+}
+
+func networkPolicyIngressRules(opts NetworkPolicyOptions) ([]networkingv1.NetworkPolicyIngressRule, error) {
+	appPorts, err := networkPolicyPorts(appTargetPorts(opts.Service))
+	if err != nil {
+		return nil, err
+	}
+
+	// replica 之间互相访问，如 StatefulSet 的集群通信
+	rules := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: appPodSelector(opts.Name)},
+			},
+		},
+	}
+
+	if len(opts.IngressNamespaces) > 0 {
+		ingressPorts, err := ingressTargetPorts(opts)
+		if err != nil {
+			return nil, err
+		}
+		ports, err := networkPolicyPorts(ingressPorts)
+		if err != nil {
+			return nil, err
+		}
+
+		var peers []networkingv1.NetworkPolicyPeer
+		for _, namespace := range opts.IngressNamespaces {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector(namespace)})
+		}
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{From: peers, Ports: ports})
+	}
+
+	if len(opts.AllowApps) > 0 {
+		peers, err := appPeers(opts.AllowApps)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{From: peers, Ports: appPorts})
+	}
+
+	for _, input := range opts.AllowCIDRs {
+		peer, port, err := ParseCIDRPeer(input)
+		if err != nil {
+			return nil, err
+		}
+		ports := appPorts
+		if port > 0 {
+			ports, _ = networkPolicyPorts([]int32{port})
+		}
+		rules = append(rules, networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{peer}, Ports: ports})
+	}
+	return rules, nil
+}
+
+func networkPolicyEgressRules(opts NetworkPolicyOptions) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	if !opts.Egress {
+		return nil, nil
+	}
+
+	udp := corev1.ProtocolUDP
+	tcp := corev1.ProtocolTCP
+	port := intstr.FromInt32(dnsPort)
+	rules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: namespaceSelector(dnsNamespace),
+					PodSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"k8s-app": "kube-dns"},
+					},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &port},
+				{Protocol: &tcp, Port: &port},
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: appPodSelector(opts.Name)},
+			},
+		},
+	}
+
+	if len(opts.EgressApps) > 0 {
+		peers, err := appPeers(opts.EgressApps)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peers})
+	}
+
+	for _, input := range opts.EgressCIDRs {
+		peer, port, err := ParseCIDRPeer(input)
+		if err != nil {
+			return nil, err
+		}
+		var ports []networkingv1.NetworkPolicyPort
+		if port > 0 {
+			ports, _ = networkPolicyPorts([]int32{port})
+		}
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{peer}, Ports: ports})
+	}
+	return rules, nil
+}
+
+// 包括 canary 的 pod
+func appPodSelector(name string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      "name",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{name, CanaryName(name)},
+			},
+		},
+	}
+}
+
+func namespaceSelector(namespace string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{namespaceNameLabel: namespace},
+	}
+}
+
+func appPeers(inputs []string) ([]networkingv1.NetworkPolicyPeer, error) {
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, input := range inputs {
+		namespace, app, found := strings.Cut(input, "/")
+		if !found {
+			namespace, app = input, input
+		}
+		if namespace == "" || app == "" {
+			return nil, fmt.Errorf("invalid format for app peer, expected '[namespace/]app', got '%s'", input)
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: namespaceSelector(namespace),
+			PodSelector:       appPodSelector(app),
+		})
+	}
+	return peers, nil
+}
+
+// 格式为 cidr[:port]，如 10.0.0.0/8 或 fd00::/8:5432
+func ParseCIDRPeer(input string) (networkingv1.NetworkPolicyPeer, int32, error) {
+	ip, rest, _ := strings.Cut(input, "/")
+	bits, portText, hasPort := strings.Cut(rest, ":")
+	cidr := ip + "/" + bits
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return networkingv1.NetworkPolicyPeer{}, 0, fmt.Errorf("invalid cidr in '%s', expected 'cidr[:port]'", input)
+	}
+
+	var port int32
+	if hasPort {
+		number, err := strconv.ParseInt(portText, 10, 32)
+		if err != nil || number < 1 || number > 65535 {
+			return networkingv1.NetworkPolicyPeer{}, 0, fmt.Errorf("invalid port number '%s' in '%s'", portText, input)
+		}
+		port = int32(number)
+	}
+	return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}, port, nil
+}
+
+// pod 实际监听的端口，即 Service 的 targetPort
+func appTargetPorts(opts ServiceOptions) []int32 {
+	ports := []int32{opts.TargetPort}
+	for _, port := range opts.ExtraPorts {
+		ports = append(ports, port.TargetPort)
+	}
+	return ports
+}
+
+func ingressTargetPorts(opts NetworkPolicyOptions) ([]int32, error) {
+	var ports []int32
+	for _, rule := range IngressRules(opts.Ingress) {
+		for _, path := range rule.Paths {
+			name := path.ServicePort
+			if name == "" {
+				name = opts.Ingress.ServicePort
+			}
+			port, err := serviceTargetPort(opts.Service, name)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+func serviceTargetPort(opts ServiceOptions, name string) (int32, error) {
+	if name == "" || name == httpPortName {
+		return opts.TargetPort, nil
+	}
+	for _, port := range opts.ExtraPorts {
+		if port.Name == name {
+			return port.TargetPort, nil
+		}
+	}
+	return 0, fmt.Errorf("service port '%s' not found", name)
+}
+
+// 去掉重复的端口，协议为 TCP
+func networkPolicyPorts(numbers []int32) ([]networkingv1.NetworkPolicyPort, error) {
+	var ports []networkingv1.NetworkPolicyPort
+	seen := map[int32]bool{}
+	for _, number := range numbers {
+		if number < 1 || number > 65535 {
+			return nil, fmt.Errorf("invalid port number %d", number)
+		}
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+		protocol := corev1.ProtocolTCP
+		port := intstr.FromInt32(number)
+		ports = append(ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+	}
+	return ports, nil
+}
+
+func DeleteNetworkPolicies(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	for _, policyName := range []string{AllowPolicyName(name), DefaultDenyPolicyName(name)} {
+		if err := remove(ctx, clientset.NetworkingV1().NetworkPolicies(namespace), policyName, namespace, "networkpolicy", quiet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
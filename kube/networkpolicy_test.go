@@ -0,0 +1,144 @@
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func TestParseCIDRPeer(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantCIDR string
+		wantPort int32
+		wantErr  bool
+	}{
+		{input: "10.0.0.0/8", wantCIDR: "10.0.0.0/8"},
+		{input: "192.168.1.0/24:5432", wantCIDR: "192.168.1.0/24", wantPort: 5432},
+		{input: "0.0.0.0/0:443", wantCIDR: "0.0.0.0/0", wantPort: 443},
+		{input: "fd00::/8", wantCIDR: "fd00::/8"},
+		{input: "fd00::/8:5432", wantCIDR: "fd00::/8", wantPort: 5432},
+		{input: "2001:db8::1/128:65535", wantCIDR: "2001:db8::1/128", wantPort: 65535},
+		{input: "10.0.0.1", wantErr: true},
+		{input: "10.0.0.0/33", wantErr: true},
+		{input: "10.0.0/8", wantErr: true},
+		{input: "fd00::/129", wantErr: true},
+		{input: "hellogo/8", wantErr: true},
+		{input: "10.0.0.0/8:", wantErr: true},
+		{input: "10.0.0.0/8:0", wantErr: true},
+		{input: "10.0.0.0/8:65536", wantErr: true},
+		{input: "10.0.0.0/8:http", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			peer, port, err := ParseCIDRPeer(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCIDRPeer() = %+v, %d, want error", peer, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCIDRPeer() error = %v", err)
+			}
+			want := networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: tt.wantCIDR}}
+			if !reflect.DeepEqual(peer, want) {
+				t.Errorf("ParseCIDRPeer() peer = %+v, want %+v", peer, want)
+			}
+			if port != tt.wantPort {
+				t.Errorf("ParseCIDRPeer() port = %d, want %d", port, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestIngressTargetPorts(t *testing.T) {
+	service := ServiceOptions{
+		Port:       80,
+		TargetPort: 8080,
+		ExtraPorts: []ServicePort{
+			{Name: "grpc", Port: 9090, TargetPort: 19090},
+			{Name: "metrics", Port: 9100, TargetPort: 19100},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ingress IngressOptions
+		want    []int32
+		wantErr bool
+	}{
+		{
+			name:    "default rule",
+			ingress: IngressOptions{Host: "hellogo.com"},
+			want:    []int32{8080},
+		},
+		{
+			name:    "default rule with service port",
+			ingress: IngressOptions{Host: "hellogo.com", ServicePort: "grpc"},
+			want:    []int32{19090},
+		},
+		{
+			name: "rules with named ports",
+			ingress: IngressOptions{
+				ServicePort: "grpc",
+				Rules: []IngressRule{
+					{
+						Host: "hellogo.com",
+						Paths: []IngressPath{
+							{Path: "/"},
+							{Path: "/metrics", ServicePort: "metrics"},
+						},
+					},
+					{
+						Host: "api.hellogo.com",
+						Paths: []IngressPath{
+							{Path: "/", ServicePort: "http"},
+						},
+					},
+				},
+			},
+			want: []int32{19090, 19100, 8080},
+		},
+		{
+			name: "unknown service port",
+			ingress: IngressOptions{
+				Rules: []IngressRule{
+					{
+						Host: "hellogo.com",
+						Paths: []IngressPath{
+							{Path: "/", ServicePort: "admin"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unknown default service port",
+			ingress: IngressOptions{Host: "hellogo.com", ServicePort: "admin"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ingressTargetPorts(NetworkPolicyOptions{Service: service, Ingress: tt.ingress})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ingressTargetPorts() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ingressTargetPorts() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ingressTargetPorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
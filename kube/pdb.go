@@ -0,0 +1,120 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+type PDBOptions struct {
+	Name           string
+	Namespace      string
+	Enabled        bool
+	MinAvailable   string // 驱逐时至少保留的 pod 数或百分比，与 MaxUnavailable 二选一
+	MaxUnavailable string // 驱逐时最多不可用的 pod 数或百分比，与 MinAvailable 二选一
+}
+
+func CreateOrUpdatePDB(clientset *kubernetes.Clientset, ctx context.Context, opts PDBOptions) error {
+	pdb, err := NewPDB(opts)
+	if err != nil {
+		return err
+	}
+	_, err = apply(ctx, clientset.PolicyV1().PodDisruptionBudgets(opts.Namespace), pdb, "pdb")
+	return err
+}
+
+// 选择 app 的所有 pod，包括蓝绿发布的两种颜色，不包括 canary
+func NewPDB(opts PDBOptions) (*policyv1.PodDisruptionBudget, error) {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: podLabels(opts.Name, ""),
+			},
+		},
+	}
+
+	if !helpers.IsBlank(opts.MinAvailable) {
+		minAvailable, err := parsePDBValue(opts.MinAvailable)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pdb min available: %v", err)
+		}
+		pdb.Spec.MinAvailable = &minAvailable
+	} else {
+		maxUnavailable, err := parsePDBValue(opts.MaxUnavailable)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pdb max unavailable: %v", err)
+		}
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+	}
+	return pdb, nil
+}
+
+// 检查 budget 与副本数范围是否一致，返回值为需要提醒的问题，如 budget 会阻止所有驱逐
+func CheckPDB(opts PDBOptions, minReplicas, maxReplicas int32) ([]string, error) {
+	var warnings []string
+
+	if !helpers.IsBlank(opts.MinAvailable) {
+		minAvailable, err := parsePDBValue(opts.MinAvailable)
+		if err != nil {
+			return nil, err
+		}
+		if minAvailable.Type == intstr.Int && minAvailable.IntVal > maxReplicas {
+			return nil, fmt.Errorf("min available %d is more than the %d replicas the app can have", minAvailable.IntVal, maxReplicas)
+		}
+		available, err := intstr.GetScaledValueFromIntOrPercent(&minAvailable, int(minReplicas), true)
+		if err != nil {
+			return nil, err
+		}
+		if available >= int(minReplicas) {
+			warnings = append(warnings, fmt.Sprintf("min available %s keeps all %d replicas, every eviction is blocked and node drains will hang", opts.MinAvailable, minReplicas))
+		}
+		return warnings, nil
+	}
+
+	maxUnavailable, err := parsePDBValue(opts.MaxUnavailable)
+	if err != nil {
+		return nil, err
+	}
+	unavailable, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, int(minReplicas), true)
+	if err != nil {
+		return nil, err
+	}
+	if unavailable == 0 {
+		warnings = append(warnings, fmt.Sprintf("max unavailable %s allows no disruption, every eviction is blocked and node drains will hang", opts.MaxUnavailable))
+	} else if unavailable >= int(minReplicas) {
+		warnings = append(warnings, fmt.Sprintf("max unavailable %s allows all %d replicas to be evicted at once", opts.MaxUnavailable, minReplicas))
+	}
+	return warnings, nil
+}
+
+// 整数或 0% 到 100% 的百分比
+func parsePDBValue(input string) (intstr.IntOrString, error) {
+	if percent, found := strings.CutSuffix(input, "%"); found {
+		value, err := strconv.Atoi(percent)
+		if err != nil || value < 0 || value > 100 {
+			return intstr.IntOrString{}, fmt.Errorf("invalid percentage '%s', expected 0%% to 100%%", input)
+		}
+		return intstr.FromString(input), nil
+	}
+
+	value, err := strconv.ParseInt(input, 10, 32)
+	if err != nil || value < 0 {
+		return intstr.IntOrString{}, fmt.Errorf("invalid value '%s', expected a non-negative integer or a percentage", input)
+	}
+	return intstr.FromInt32(int32(value)), nil
+}
+
+func DeletePDB(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.PolicyV1().PodDisruptionBudgets(namespace), name, namespace, "pdb", quiet)
+}
@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"testing"
+)
+
+func TestCheckPDB(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         PDBOptions
+		minReplicas  int32
+		maxReplicas  int32
+		wantWarnings int
+		wantErr      bool
+	}{
+		{name: "min available below replicas", opts: PDBOptions{MinAvailable: "1"}, minReplicas: 2, maxReplicas: 2},
+		{name: "min available equals replicas", opts: PDBOptions{MinAvailable: "2"}, minReplicas: 2, maxReplicas: 2, wantWarnings: 1},
+		{name: "min available above min replicas", opts: PDBOptions{MinAvailable: "3"}, minReplicas: 2, maxReplicas: 5, wantWarnings: 1},
+		{name: "min available above max replicas", opts: PDBOptions{MinAvailable: "6"}, minReplicas: 2, maxReplicas: 5, wantErr: true},
+		{name: "min available percent", opts: PDBOptions{MinAvailable: "50%"}, minReplicas: 4, maxReplicas: 4},
+		{name: "min available percent rounds up to all replicas", opts: PDBOptions{MinAvailable: "60%"}, minReplicas: 1, maxReplicas: 3, wantWarnings: 1},
+		{name: "min available 100 percent", opts: PDBOptions{MinAvailable: "100%"}, minReplicas: 3, maxReplicas: 3, wantWarnings: 1},
+		{name: "max unavailable below replicas", opts: PDBOptions{MaxUnavailable: "1"}, minReplicas: 3, maxReplicas: 3},
+		{name: "max unavailable zero", opts: PDBOptions{MaxUnavailable: "0"}, minReplicas: 3, maxReplicas: 3, wantWarnings: 1},
+		{name: "max unavailable zero percent", opts: PDBOptions{MaxUnavailable: "0%"}, minReplicas: 3, maxReplicas: 3, wantWarnings: 1},
+		{name: "max unavailable all replicas", opts: PDBOptions{MaxUnavailable: "3"}, minReplicas: 3, maxReplicas: 3, wantWarnings: 1},
+		{name: "max unavailable percent", opts: PDBOptions{MaxUnavailable: "25%"}, minReplicas: 4, maxReplicas: 8},
+		{name: "max unavailable percent rounds up to all replicas", opts: PDBOptions{MaxUnavailable: "25%"}, minReplicas: 1, maxReplicas: 4, wantWarnings: 1},
+		{name: "invalid min available", opts: PDBOptions{MinAvailable: "-1"}, minReplicas: 2, maxReplicas: 2, wantErr: true},
+		{name: "invalid min available percent", opts: PDBOptions{MinAvailable: "120%"}, minReplicas: 2, maxReplicas: 2, wantErr: true},
+		{name: "invalid max unavailable", opts: PDBOptions{MaxUnavailable: "one"}, minReplicas: 2, maxReplicas: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckPDB(tt.opts, tt.minReplicas, tt.maxReplicas)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("CheckPDB() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckPDB() error = %v", err)
+			}
+			if len(got) != tt.wantWarnings {
+				t.Errorf("CheckPDB() = %q, want %d warnings", got, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -21,7 +20,12 @@ type PVCOptions struct {
 }
 
 func CreateOrUpdatePVC(clientset *kubernetes.Clientset, ctx context.Context, opts PVCOptions) error {
-	pvc := &corev1.PersistentVolumeClaim{
+	_, err := apply(ctx, clientset.CoreV1().PersistentVolumeClaims(opts.Namespace), NewPVC(opts), "pvc")
+	return err
+}
+
+func NewPVC(opts PVCOptions) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      opts.Name,
 			Namespace: opts.Namespace,
@@ -38,30 +42,10 @@ func CreateOrUpdatePVC(clientset *kubernetes.Clientset, ctx context.Context, opt
 			},
 		},
 	}
-
-	if _, err := clientset.CoreV1().PersistentVolumeClaims(opts.Namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create pvc resource: %v", err)
-		}
-		fmt.Println("pvc resource successfully updated")
-	} else {
-		fmt.Println("pvc resource successfully created")
-	}
-
-	return nil
 }
 
-func DeletePVC(clientset *kubernetes.Clientset, ctx context.Context, opts HPAOptions) error {
-	err := clientset.CoreV1().PersistentVolumeClaims(opts.Namespace).Delete(ctx, opts.Name, metav1.DeleteOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		return fmt.Errorf("failed to delete hpa resource: %v", err)
-	}
-	if apierrors.IsNotFound(err) {
-		fmt.Printf("pvc resource %s in namespace %s not found, no action taken\n", opts.Name, opts.Namespace)
-	} else {
-		fmt.Printf("pvc resource %s in namespace %s successfully deleted\n", opts.Name, opts.Namespace)
-	}
-	return nil
+func DeletePVC(clientset *kubernetes.Clientset, ctx context.Context, opts PVCOptions) error {
+	return remove(ctx, clientset.CoreV1().PersistentVolumeClaims(opts.Namespace), opts.Name, opts.Namespace, "pvc", false)
 }
 
 func MustConvert(v string) corev1.PersistentVolumeAccessMode {
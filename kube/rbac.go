@@ -0,0 +1,203 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// 授予 app 的 ServiceAccount 的权限，Role 和 RoleBinding 与 app 同名
+type RBACOptions struct {
+	Name         string
+	Namespace    string
+	ClusterScope bool // 使用 ClusterRole 和 ClusterRoleBinding，权限覆盖所有命名空间
+	Rules        []RBACRule
+}
+
+type RBACRule struct {
+	Name            string   // 配置中的名称，仅用于错误提示
+	APIGroups       []string // 为空或 core 表示核心 API 组
+	Resources       []string
+	Verbs           []string
+	ResourceNames   []string
+	NonResourceURLs []string // 如 /healthz，只能用于 ClusterRole
+}
+
+// 在两种范围间切换时删除另一种范围的资源
+func CreateOrUpdateRBAC(clientset *kubernetes.Clientset, ctx context.Context, opts RBACOptions) error {
+	if opts.ClusterScope {
+		clusterRole, err := NewClusterRole(opts)
+		if err != nil {
+			return err
+		}
+		if _, err := apply(ctx, clientset.RbacV1().ClusterRoles(), clusterRole, "clusterrole"); err != nil {
+			return err
+		}
+		if _, err := apply(ctx, clientset.RbacV1().ClusterRoleBindings(), NewClusterRoleBinding(opts), "clusterrolebinding"); err != nil {
+			return err
+		}
+		return deleteNamespacedRBAC(clientset, ctx, opts.Name, opts.Namespace, true)
+	}
+
+	role, err := NewRole(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := apply(ctx, clientset.RbacV1().Roles(opts.Namespace), role, "role"); err != nil {
+		return err
+	}
+	if _, err := apply(ctx, clientset.RbacV1().RoleBindings(opts.Namespace), NewRoleBinding(opts), "rolebinding"); err != nil {
+		return err
+	}
+	return deleteClusterRBAC(clientset, ctx, opts.Name, opts.Namespace, true)
+}
+
+func NewRole(opts RBACOptions) (*rbacv1.Role, error) {
+	rules, err := policyRules(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Rules: rules,
+	}, nil
+}
+
+func NewRoleBinding(opts RBACOptions) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Subjects: serviceAccountSubjects(opts),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     opts.Name,
+		},
+	}
+}
+
+func NewClusterRole(opts RBACOptions) (*rbacv1.ClusterRole, error) {
+	rules, err := policyRules(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ClusterRoleName(opts.Name, opts.Namespace),
+		},
+		Rules: rules,
+	}, nil
+}
+
+func NewClusterRoleBinding(opts RBACOptions) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ClusterRoleName(opts.Name, opts.Namespace),
+		},
+		Subjects: serviceAccountSubjects(opts),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     ClusterRoleName(opts.Name, opts.Namespace),
+		},
+	}
+}
+
+// 集群级别的资源没有命名空间，加上命名空间前缀避免不同命名空间的同名 app 冲突
+func ClusterRoleName(name, namespace string) string {
+	return namespace + "-" + name
+}
+
+func serviceAccountSubjects(opts RBACOptions) []rbacv1.Subject {
+	return []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+	}
+}
+
+func policyRules(opts RBACOptions) ([]rbacv1.PolicyRule, error) {
+	if len(opts.Rules) == 0 {
+		return nil, fmt.Errorf("rbac requires at least one rule")
+	}
+
+	var rules []rbacv1.PolicyRule
+	for _, rule := range opts.Rules {
+		if len(rule.Verbs) == 0 {
+			return nil, fmt.Errorf("verbs of rbac rule %s are required", rule.Name)
+		}
+		if (len(rule.Resources) == 0) == (len(rule.NonResourceURLs) == 0) {
+			return nil, fmt.Errorf("rbac rule %s requires either resources or nonresourceurls", rule.Name)
+		}
+
+		verbs := make([]string, len(rule.Verbs))
+		for i, verb := range rule.Verbs {
+			verbs[i] = strings.ToLower(verb)
+		}
+
+		if len(rule.NonResourceURLs) > 0 {
+			if !opts.ClusterScope {
+				return nil, fmt.Errorf("nonresourceurls of rbac rule %s are only supported with cluster scope", rule.Name)
+			}
+			if len(rule.APIGroups) > 0 || len(rule.ResourceNames) > 0 {
+				return nil, fmt.Errorf("rbac rule %s cannot mix nonresourceurls with apigroups or resourcenames", rule.Name)
+			}
+			rules = append(rules, rbacv1.PolicyRule{
+				Verbs:           verbs,
+				NonResourceURLs: rule.NonResourceURLs,
+			})
+			continue
+		}
+
+		apiGroups := []string{""}
+		if len(rule.APIGroups) > 0 {
+			apiGroups = make([]string, len(rule.APIGroups))
+			for i, group := range rule.APIGroups {
+				if strings.EqualFold(group, "core") {
+					group = ""
+				}
+				apiGroups[i] = group
+			}
+		}
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     apiGroups,
+			Resources:     rule.Resources,
+			Verbs:         verbs,
+			ResourceNames: rule.ResourceNames,
+		})
+	}
+	return rules, nil
+}
+
+func DeleteRBAC(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	if err := deleteNamespacedRBAC(clientset, ctx, name, namespace, quiet); err != nil {
+		return err
+	}
+	return deleteClusterRBAC(clientset, ctx, name, namespace, quiet)
+}
+
+func deleteNamespacedRBAC(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	if err := remove(ctx, clientset.RbacV1().RoleBindings(namespace), name, namespace, "rolebinding", quiet); err != nil {
+		return err
+	}
+	return remove(ctx, clientset.RbacV1().Roles(namespace), name, namespace, "role", quiet)
+}
+
+func deleteClusterRBAC(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	clusterRoleName := ClusterRoleName(name, namespace)
+	if err := remove(ctx, clientset.RbacV1().ClusterRoleBindings(), clusterRoleName, "", "clusterrolebinding", quiet); err != nil {
+		return err
+	}
+	return remove(ctx, clientset.RbacV1().ClusterRoles(), clusterRoleName, "", "clusterrole", quiet)
+}
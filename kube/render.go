@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/guobinqiu/appdeployer/helpers"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// 由 apiserver 填充的 metadata 字段，渲染和对比时忽略
+var serverMetadataFields = []string{
+	"creationTimestamp",
+	"deletionGracePeriodSeconds",
+	"deletionTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// 将对象转换为去掉服务端字段的清单
+func ToManifest(obj runtime.Object) (map[string]interface{}, error) {
+	if err := setTypeMeta(obj); err != nil {
+		return nil, err
+	}
+
+	manifest, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %T: %v", obj, err)
+	}
+
+	cleanManifest(manifest)
+	return manifest, nil
+}
+
+func cleanManifest(manifest map[string]interface{}) {
+	delete(manifest, "status")
+	if metadata, ok := manifest["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverMetadataFields {
+			delete(metadata, field)
+		}
+	}
+	removeEmptyFields(manifest)
+}
+
+// 类型化对象中的 pod 模板等也会带有 creationTimestamp: null，volumeClaimTemplates 还会带有 status: {}
+func removeEmptyFields(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key == "creationTimestamp" && child == nil {
+				delete(v, key)
+				continue
+			}
+			if status, ok := child.(map[string]interface{}); ok && key == "status" && len(status) == 0 {
+				delete(v, key)
+				continue
+			}
+			removeEmptyFields(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			removeEmptyFields(child)
+		}
+	}
+}
+
+func ToYAML(obj runtime.Object) ([]byte, error) {
+	manifest, err := ToManifest(obj)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(manifest)
+}
+
+// 将多个对象渲染为以 --- 分隔的 YAML 文档
+func RenderYAML(w io.Writer, objs []runtime.Object) error {
+	for i, obj := range objs {
+		data, err := ToYAML(obj)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write manifest: %v", err)
+		}
+	}
+	return nil
+}
+
+// 每个对象写入一个文件，文件名带序号以保持 kubectl apply -f 时的创建顺序
+func RenderYAMLFiles(dir string, objs []runtime.Object) error {
+	for i, obj := range objs {
+		data, err := ToYAML(obj)
+		if err != nil {
+			return err
+		}
+
+		kind := strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
+		name, err := objectName(obj)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%02d-%s-%s.yaml", i+1, kind, name))
+		if err := helpers.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("%s written\n", path)
+	}
+	return nil
+}
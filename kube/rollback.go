@@ -0,0 +1,190 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	revisionAnnotation        = "deployment.kubernetes.io/revision"
+	revisionHistoryAnnotation = "deployment.kubernetes.io/revision-history" // ReplicaSet 被再次启用时记录之前的版本号
+)
+
+type RollbackOptions struct {
+	Name      string
+	Namespace string
+	Revision  int64 // 0 表示回滚到上一个版本
+}
+
+// Deployment 的一个历史版本，对应一个 ReplicaSet
+type Revision struct {
+	Number     int64
+	Images     []string
+	CreatedAt  time.Time // ReplicaSet 的创建时间，即该 pod 模板首次发布的时间
+	Redeployed bool      // 回滚或重新发布时复用了之前的 ReplicaSet，成为该版本的时间晚于 CreatedAt
+	Current    bool
+	Template   corev1.PodTemplateSpec
+}
+
+// 列出 app 的所有历史版本，按版本号升序排列
+func ListRevisions(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string) ([]Revision, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment resource: %v", err)
+	}
+	current, _ := strconv.ParseInt(deployment.Annotations[revisionAnnotation], 10, 64)
+
+	replicaSets, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "name=" + name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicaset resources: %v", err)
+	}
+
+	var revisions []Revision
+	for _, rs := range replicaSets.Items {
+		if !isOwnedBy(rs, deployment) {
+			continue
+		}
+		number, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		var images []string
+		for _, container := range rs.Spec.Template.Spec.Containers {
+			images = append(images, container.Image)
+		}
+
+		revisions = append(revisions, Revision{
+			Number:     number,
+			Images:     images,
+			CreatedAt:  rs.CreationTimestamp.Time,
+			Redeployed: rs.Annotations[revisionHistoryAnnotation] != "",
+			Current:    number == current,
+			Template:   rs.Spec.Template,
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Number < revisions[j].Number
+	})
+	return revisions, nil
+}
+
+// 将 Deployment 的 pod 模板恢复为指定版本
+func Rollback(clientset *kubernetes.Clientset, ctx context.Context, opts RollbackOptions) error {
+	revisions, err := ListRevisions(clientset, ctx, opts.Name, opts.Namespace)
+	if err != nil {
+		return err
+	}
+
+	target, err := findRevision(revisions, opts.Revision)
+	if err != nil {
+		return err
+	}
+	if target.Current {
+		fmt.Printf("revision %d is already the current revision, no action taken\n", target.Number)
+		return nil
+	}
+
+	// pod-template-hash 由 ReplicaSet 控制器添加，不属于 Deployment 的模板
+	template := *target.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+
+	deployment, err := clientset.AppsV1().Deployments(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment resource: %v", err)
+	}
+	if equality.Semantic.DeepEqual(deployment.Spec.Template, template) {
+		fmt.Printf("revision %d has the same pod template as the current revision, no action taken\n", target.Number)
+		return nil
+	}
+	for _, image := range sharedMutableImages(deployment.Spec.Template, template) {
+		fmt.Fprintf(os.Stderr, "warning: revision %d uses the same image %s as the current revision, which is not pinned by digest, so the rollback runs whatever the tag points to now\n", target.Number, image)
+	}
+
+	patch, err := json.Marshal([]map[string]interface{}{
+		{
+			"op":    "replace",
+			"path":  "/spec/template",
+			"value": template,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollback patch: %v", err)
+	}
+
+	if _, err := clientset.AppsV1().Deployments(opts.Namespace).Patch(ctx, opts.Name, types.JSONPatchType, patch, metav1.PatchOptions{
+		FieldManager: FieldManager,
+	}); err != nil {
+		return fmt.Errorf("failed to roll back deployment resource: %v", err)
+	}
+
+	fmt.Printf("deployment %s rolled back to revision %d (%s)\n", opts.Name, target.Number, strings.Join(target.Images, ", "))
+	return nil
+}
+
+func findRevision(revisions []Revision, number int64) (Revision, error) {
+	if number > 0 {
+		for _, revision := range revisions {
+			if revision.Number == number {
+				return revision, nil
+			}
+		}
+		return Revision{}, fmt.Errorf("revision %d not found", number)
+	}
+
+	// 默认回滚到当前版本之前的最近一个版本
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].Current {
+			if i == 0 {
+				break
+			}
+			return revisions[i-1], nil
+		}
+	}
+	return Revision{}, fmt.Errorf("no previous revision found")
+}
+
+// 两个模板中同名容器使用的相同镜像，未用 digest 固定时标签可能已被重新推送，回滚无法恢复之前的镜像
+func sharedMutableImages(current, target corev1.PodTemplateSpec) []string {
+	images := map[string]string{}
+	for _, containers := range [][]corev1.Container{current.Spec.InitContainers, current.Spec.Containers} {
+		for _, container := range containers {
+			images[container.Name] = container.Image
+		}
+	}
+
+	var shared []string
+	for _, containers := range [][]corev1.Container{target.Spec.InitContainers, target.Spec.Containers} {
+		for _, container := range containers {
+			if images[container.Name] == container.Image && !strings.Contains(container.Image, "@") {
+				shared = append(shared, container.Image)
+			}
+		}
+	}
+	return shared
+}
+
+func isOwnedBy(rs appsv1.ReplicaSet, deployment *appsv1.Deployment) bool {
+	for _, ref := range rs.OwnerReferences {
+		if ref.UID == deployment.UID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package kube
+
+import (
+	"testing"
+)
+
+func TestFindRevision(t *testing.T) {
+	revisions := []Revision{
+		{Number: 1, Images: []string{"hellogo:1"}},
+		{Number: 3, Images: []string{"hellogo:3"}},
+		{Number: 4, Images: []string{"hellogo:4"}, Current: true},
+		{Number: 5, Images: []string{"hellogo:5"}},
+	}
+
+	tests := []struct {
+		name      string
+		revisions []Revision
+		number    int64
+		want      int64
+		wantErr   bool
+	}{
+		{name: "previous of current", revisions: revisions, want: 3},
+		{name: "explicit revision", revisions: revisions, number: 1, want: 1},
+		{name: "explicit newer revision", revisions: revisions, number: 5, want: 5},
+		{name: "explicit current revision", revisions: revisions, number: 4, want: 4},
+		{name: "missing revision", revisions: revisions, number: 2, wantErr: true},
+		{
+			name: "current is the oldest",
+			revisions: []Revision{
+				{Number: 2, Current: true},
+				{Number: 3},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "only one revision",
+			revisions: []Revision{{Number: 1, Current: true}},
+			wantErr:   true,
+		},
+		{
+			name:      "no current revision",
+			revisions: []Revision{{Number: 1}, {Number: 2}},
+			wantErr:   true,
+		},
+		{name: "no revisions", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findRevision(tt.revisions, tt.number)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("findRevision() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findRevision() error = %v", err)
+			}
+			if got.Number != tt.want {
+				t.Errorf("findRevision() = revision %d, want revision %d", got.Number, tt.want)
+			}
+		})
+	}
+}
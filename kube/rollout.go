@@ -0,0 +1,230 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	rolloutPollInterval = 2 * time.Second
+	maxWarningEvents    = 10
+)
+
+type RolloutOptions struct {
+	Name      string
+	Namespace string
+	Timeout   time.Duration
+	Workload  string // 为空表示 Deployment
+}
+
+var errProgressDeadlineExceeded = errors.New("progress deadline exceeded")
+
+// 等待工作负载滚动更新完成，失败时打印异常 pod 的容器状态和最近的 Warning 事件
+func WaitForRollout(clientset *kubernetes.Clientset, ctx context.Context, opts RolloutOptions) error {
+	var lastMessage string
+
+	err := wait.PollUntilContextTimeout(ctx, rolloutPollInterval, opts.Timeout, true, func(ctx context.Context) (bool, error) {
+		message, done, err := workloadRolloutStatus(clientset, ctx, opts)
+		if message != lastMessage {
+			fmt.Println(message)
+			lastMessage = message
+		}
+		return done, err
+	})
+	if err == nil {
+		if opts.Workload == WorkloadJob {
+			fmt.Printf("job %s successfully completed\n", opts.Name)
+		} else {
+			fmt.Printf("%s %s successfully rolled out\n", workloadName(opts.Workload), opts.Name)
+		}
+		return nil
+	}
+
+	if wait.Interrupted(err) {
+		err = fmt.Errorf("timed out after %s", opts.Timeout)
+	}
+	printRolloutFailure(clientset, context.Background(), opts)
+	return fmt.Errorf("%s %s rollout failed: %v", workloadName(opts.Workload), opts.Name, err)
+}
+
+func workloadName(workload string) string {
+	if workload == "" {
+		return WorkloadDeployment
+	}
+	return workload
+}
+
+func workloadRolloutStatus(clientset *kubernetes.Clientset, ctx context.Context, opts RolloutOptions) (string, bool, error) {
+	switch opts.Workload {
+	case WorkloadStatefulSet:
+		statefulSet, err := clientset.AppsV1().StatefulSets(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get statefulset resource: %v", err)
+		}
+		return statefulSetRolloutStatus(statefulSet)
+	case WorkloadDaemonSet:
+		daemonSet, err := clientset.AppsV1().DaemonSets(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get daemonset resource: %v", err)
+		}
+		return daemonSetRolloutStatus(daemonSet)
+	case WorkloadJob:
+		job, err := clientset.BatchV1().Jobs(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get job resource: %v", err)
+		}
+		return jobStatus(job)
+	default:
+		deployment, err := clientset.AppsV1().Deployments(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get deployment resource: %v", err)
+		}
+		return rolloutStatus(deployment)
+	}
+}
+
+// 工作负载的 pod 选择器，获取失败时退回到 name 标签
+func workloadSelector(clientset *kubernetes.Clientset, ctx context.Context, opts RolloutOptions) string {
+	var selector *metav1.LabelSelector
+	switch opts.Workload {
+	case WorkloadStatefulSet:
+		if statefulSet, err := clientset.AppsV1().StatefulSets(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{}); err == nil {
+			selector = statefulSet.Spec.Selector
+		}
+	case WorkloadDaemonSet:
+		if daemonSet, err := clientset.AppsV1().DaemonSets(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{}); err == nil {
+			selector = daemonSet.Spec.Selector
+		}
+	case WorkloadJob:
+		if job, err := clientset.BatchV1().Jobs(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{}); err == nil {
+			selector = job.Spec.Selector
+		}
+	default:
+		if deployment, err := clientset.AppsV1().Deployments(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{}); err == nil {
+			selector = deployment.Spec.Selector
+		}
+	}
+	if selector == nil {
+		return "name=" + opts.Name
+	}
+	return metav1.FormatLabelSelector(selector)
+}
+
+// 参照 kubectl rollout status 的判断逻辑
+func rolloutStatus(deployment *appsv1.Deployment) (string, bool, error) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return "waiting for deployment spec update to be observed...", false, nil
+	}
+
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return cond.Message, false, errProgressDeadlineExceeded
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+
+	if status.UpdatedReplicas < replicas {
+		return fmt.Sprintf("waiting for rollout to finish: %d out of %d new replicas have been updated...", status.UpdatedReplicas, replicas), false, nil
+	}
+	if status.Replicas > status.UpdatedReplicas {
+		return fmt.Sprintf("waiting for rollout to finish: %d old replicas are pending termination...", status.Replicas-status.UpdatedReplicas), false, nil
+	}
+	if status.AvailableReplicas < status.UpdatedReplicas {
+		return fmt.Sprintf("waiting for rollout to finish: %d of %d updated replicas are available...", status.AvailableReplicas, status.UpdatedReplicas), false, nil
+	}
+	return fmt.Sprintf("%d of %d updated replicas are available", status.AvailableReplicas, status.UpdatedReplicas), true, nil
+}
+
+func printRolloutFailure(clientset *kubernetes.Clientset, ctx context.Context, opts RolloutOptions) {
+	selector := workloadSelector(clientset, ctx, opts)
+
+	pods, err := clientset.CoreV1().Pods(opts.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		fmt.Printf("failed to list pods: %v\n", err)
+	} else {
+		for _, pod := range pods.Items {
+			printPodStatus(pod)
+		}
+	}
+
+	events, err := clientset.CoreV1().Events(opts.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + corev1.EventTypeWarning,
+	})
+	if err != nil {
+		fmt.Printf("failed to list events: %v\n", err)
+		return
+	}
+
+	var warnings []corev1.Event
+	for _, event := range events.Items {
+		if strings.HasPrefix(event.InvolvedObject.Name, opts.Name) {
+			warnings = append(warnings, event)
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return eventTime(warnings[i]).Before(eventTime(warnings[j]))
+	})
+	if len(warnings) > maxWarningEvents {
+		warnings = warnings[len(warnings)-maxWarningEvents:]
+	}
+
+	if len(warnings) > 0 {
+		fmt.Println("recent warning events:")
+	}
+	for _, event := range warnings {
+		fmt.Printf("  %s %s/%s %s: %s\n", eventTime(event).Format(time.RFC3339), strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name, event.Reason, strings.TrimSpace(event.Message))
+	}
+}
+
+func printPodStatus(pod corev1.Pod) {
+	var lines []string
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.Ready {
+			continue
+		}
+		state := "running"
+		switch {
+		case status.State.Waiting != nil:
+			state = fmt.Sprintf("waiting (%s: %s)", status.State.Waiting.Reason, status.State.Waiting.Message)
+		case status.State.Terminated != nil:
+			state = fmt.Sprintf("terminated (%s, exit code %d: %s)", status.State.Terminated.Reason, status.State.Terminated.ExitCode, status.State.Terminated.Message)
+		}
+		lines = append(lines, fmt.Sprintf("  container %s: %s, restarts %d", status.Name, state, status.RestartCount))
+	}
+	if len(lines) == 0 && pod.Status.Phase == corev1.PodRunning {
+		return
+	}
+
+	fmt.Printf("pod %s is %s\n", pod.Name, pod.Status.Phase)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
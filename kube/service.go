@@ -3,6 +3,9 @@ package kube
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -11,42 +14,152 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// Service 类型，不区分大小写
+const (
+	ServiceTypeClusterIP    = "clusterip"
+	ServiceTypeNodePort     = "nodeport"
+	ServiceTypeLoadBalancer = "loadbalancer"
+	ServiceTypeHeadless     = "headless"
+)
+
+// app 容器的主端口名称，探针、蓝绿和金丝雀发布都使用这个端口
+const httpPortName = "http"
+
+// 容器端口的名称最长 15 个字符
+var portNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,13}[a-z0-9])?$`)
+
 type ServiceOptions struct {
+	Name                  string
+	Namespace             string
+	Port                  int32
+	TargetPort            int32
+	NodePort              int32         // http 端口的 nodePort，0 表示由集群分配
+	ExtraPorts            []ServicePort // 除 http 以外的端口，如 metrics、grpc
+	Type                  string
+	SessionAffinity       string // None 或 ClientIP
+	ExternalTrafficPolicy string // Cluster 或 Local，只对 NodePort 和 LoadBalancer 有效
+	Color                 string // 蓝绿发布时 Service 指向的颜色
+}
+
+type ServicePort struct {
 	Name       string
-	Namespace  string
 	Port       int32
-	TargetPort int32
+	TargetPort int32 // app 容器上同名的端口
+	NodePort   int32
 }
 
 func CreateOrUpdateService(clientset *kubernetes.Clientset, ctx context.Context, opts ServiceOptions) error {
+	service := NewService(opts)
+
+	// clusterIP 不可修改，在普通 Service 和无头 Service 之间切换时需要重新创建
+	existing, err := clientset.CoreV1().Services(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get service resource: %v", err)
+	}
+	if err == nil && (existing.Spec.ClusterIP == corev1.ClusterIPNone) != (service.Spec.ClusterIP == corev1.ClusterIPNone) {
+		if err := DeleteService(clientset, ctx, opts.Name, opts.Namespace, false); err != nil {
+			return err
+		}
+	}
+
+	_, err = apply(ctx, clientset.CoreV1().Services(opts.Namespace), service, "service")
+	return err
+}
+
+func NewService(opts ServiceOptions) *corev1.Service {
+	ports := []corev1.ServicePort{
+		{
+			Name:       httpPortName,
+			Port:       opts.Port,
+			TargetPort: intstr.FromInt32(opts.TargetPort),
+			NodePort:   opts.NodePort,
+		},
+	}
+	for _, port := range opts.ExtraPorts {
+		ports = append(ports, corev1.ServicePort{
+			Name:       port.Name,
+			Port:       port.Port,
+			TargetPort: intstr.FromInt32(port.TargetPort),
+			NodePort:   port.NodePort,
+		})
+	}
+
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      opts.Name,
 			Namespace: opts.Namespace,
 		},
 		Spec: corev1.ServiceSpec{
-			Type: corev1.ServiceTypeClusterIP,
-			Ports: []corev1.ServicePort{
-				{
-					Name:       "http",
-					Port:       opts.Port,
-					TargetPort: intstr.FromInt32(opts.TargetPort),
-				},
-			},
-			Selector: map[string]string{
-				"name": opts.Name,
-			},
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports:    ports,
+			Selector: podLabels(opts.Name, opts.Color),
 		},
 	}
 
-	if _, err := clientset.CoreV1().Services(opts.Namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create service resource: %v", err)
-		}
-		fmt.Println("service resource successfully updated")
+	switch strings.ToLower(opts.Type) {
+	case ServiceTypeNodePort:
+		service.Spec.Type = corev1.ServiceTypeNodePort
+	case ServiceTypeLoadBalancer:
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+	case ServiceTypeHeadless:
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+	if opts.SessionAffinity != "" {
+		service.Spec.SessionAffinity = corev1.ServiceAffinity(opts.SessionAffinity)
+	}
+	if service.Spec.Type != corev1.ServiceTypeClusterIP {
+		service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicy(opts.ExternalTrafficPolicy)
 	} else {
-		fmt.Println("service resource successfully created")
+		for i := range service.Spec.Ports {
+			service.Spec.Ports[i].NodePort = 0
+		}
+	}
+
+	return service
+}
+
+// 只在集群内部访问的 Service，不占用 nodePort 和负载均衡器，如金丝雀和 StatefulSet 的 Service
+func newClusterIPService(opts ServiceOptions) *corev1.Service {
+	opts.Type = ServiceTypeClusterIP
+	return NewService(opts)
+}
+
+func DeleteService(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	return remove(ctx, clientset.CoreV1().Services(namespace), name, namespace, "service", quiet)
+}
+
+// 解析 name:port[:targetPort[:nodePort]]，targetPort 默认与 port 相同
+func ParseServicePort(input string) (ServicePort, error) {
+	parts := strings.Split(input, ":")
+	if len(parts) < 2 || len(parts) > 4 {
+		return ServicePort{}, fmt.Errorf("invalid format for service port, expected 'name:port[:targetPort[:nodePort]]', got '%s'", input)
+	}
+	if !portNameRegexp.MatchString(parts[0]) {
+		return ServicePort{}, fmt.Errorf("invalid port name '%s', expected at most 15 lowercase alphanumeric characters or '-'", parts[0])
+	}
+	if parts[0] == httpPortName {
+		return ServicePort{}, fmt.Errorf("port name '%s' is reserved for the app port", httpPortName)
 	}
 
-	return nil
+	var numbers []int32
+	for _, part := range parts[1:] {
+		number, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || number < 1 || number > 65535 {
+			return ServicePort{}, fmt.Errorf("invalid port number '%s' in '%s'", part, input)
+		}
+		numbers = append(numbers, int32(number))
+	}
+
+	port := ServicePort{
+		Name:       parts[0],
+		Port:       numbers[0],
+		TargetPort: numbers[0],
+	}
+	if len(numbers) > 1 {
+		port.TargetPort = numbers[1]
+	}
+	if len(numbers) > 2 {
+		port.NodePort = numbers[2]
+	}
+	return port, nil
 }
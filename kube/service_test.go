@@ -0,0 +1,68 @@
+package kube
+
+import (
+	"testing"
+)
+
+func TestParseServicePort(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ServicePort
+		wantErr bool
+	}{
+		{
+			input: "metrics:9090",
+			want:  ServicePort{Name: "metrics", Port: 9090, TargetPort: 9090},
+		},
+		{
+			input: "grpc:9000:50051",
+			want:  ServicePort{Name: "grpc", Port: 9000, TargetPort: 50051},
+		},
+		{
+			input: "admin:8080:8081:30080",
+			want:  ServicePort{Name: "admin", Port: 8080, TargetPort: 8081, NodePort: 30080},
+		},
+		{
+			input: "a:1",
+			want:  ServicePort{Name: "a", Port: 1, TargetPort: 1},
+		},
+		{
+			input: "max-port-name-1:65535",
+			want:  ServicePort{Name: "max-port-name-1", Port: 65535, TargetPort: 65535},
+		},
+		{input: "metrics", wantErr: true},
+		{input: "metrics:9090:9091:30000:1", wantErr: true},
+		{input: ":9090", wantErr: true},
+		{input: "Metrics:9090", wantErr: true},
+		{input: "-metrics:9090", wantErr: true},
+		{input: "metrics-:9090", wantErr: true},
+		{input: "metrics_1:9090", wantErr: true},
+		{input: "port-name-too-long:9090", wantErr: true},
+		{input: "http:9090", wantErr: true},
+		{input: "metrics:0", wantErr: true},
+		{input: "metrics:65536", wantErr: true},
+		{input: "metrics:-1", wantErr: true},
+		{input: "metrics:9090:0", wantErr: true},
+		{input: "metrics:9090:9090:70000", wantErr: true},
+		{input: "metrics:abc", wantErr: true},
+		{input: "metrics:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseServicePort(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseServicePort() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseServicePort() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseServicePort() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,20 +2,24 @@ package kube
 
 import (
 	"context"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 type ServiceAccountOptions struct {
-	Name      string
-	Namespace string
+	Name                  string
+	Namespace             string
+	DisableAutomountToken bool // 应用不调用 k8s API 时不自动挂载 token
 }
 
 func CreateOrUpdateServiceAccount(clientset *kubernetes.Clientset, ctx context.Context, opts ServiceAccountOptions) error {
+	_, err := apply(ctx, clientset.CoreV1().ServiceAccounts(opts.Namespace), NewServiceAccount(opts), "serviceaccount")
+	return err
+}
+
+func NewServiceAccount(opts ServiceAccountOptions) *corev1.ServiceAccount {
 	serviceAccount := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      opts.Name,
@@ -27,15 +31,13 @@ func CreateOrUpdateServiceAccount(clientset *kubernetes.Clientset, ctx context.C
 			},
 		},
 	}
-
-	if _, err := clientset.CoreV1().ServiceAccounts(opts.Namespace).Create(ctx, serviceAccount, metav1.CreateOptions{}); err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create serviceaccount resource: %v", err)
-		}
-		fmt.Println("serviceaccount resource successfully updated")
-	} else {
-		fmt.Println("serviceaccount resource successfully created")
+	if opts.DisableAutomountToken {
+		automount := false
+		serviceAccount.AutomountServiceAccountToken = &automount
 	}
+	return serviceAccount
+}
 
-	return nil
+func DeleteServiceAccount(clientset *kubernetes.Clientset, ctx context.Context, opts ServiceAccountOptions) error {
+	return remove(ctx, clientset.CoreV1().ServiceAccounts(opts.Namespace), opts.Name, opts.Namespace, "serviceaccount", false)
 }
@@ -0,0 +1,144 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	WorkloadDeployment  = "deployment"
+	WorkloadStatefulSet = "statefulset"
+)
+
+type StatefulSetOptions struct {
+	Deployment DeploymentOptions // 复用 pod 模板相关的选项
+	Service    ServiceOptions
+	PVC        PVCOptions // 启用卷挂载时作为 volumeClaimTemplates，每个副本一个 pvc
+}
+
+// 无头 Service 为每个副本提供稳定的 DNS 名称 <app>-<n>.<app>-headless
+func HeadlessServiceName(name string) string {
+	return name + "-headless"
+}
+
+func CreateOrUpdateStatefulSet(clientset *kubernetes.Clientset, ctx context.Context, opts StatefulSetOptions) error {
+	statefulSet, err := NewStatefulSet(opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := apply(ctx, clientset.CoreV1().Services(opts.Service.Namespace), NewHeadlessService(opts.Service), "service"); err != nil {
+		return err
+	}
+	_, err = apply(ctx, clientset.AppsV1().StatefulSets(opts.Deployment.Namespace), statefulSet, "statefulset")
+	return err
+}
+
+func NewStatefulSet(opts StatefulSetOptions) (*appsv1.StatefulSet, error) {
+	template, err := NewPodTemplate(opts.Deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := podLabels(opts.Deployment.Name, "")
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Deployment.Name,
+			Namespace: opts.Deployment.Namespace,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			ServiceName: HeadlessServiceName(opts.Deployment.Name),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: template,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			},
+		},
+	}
+	if !opts.Deployment.HPAEnabled {
+		statefulSet.Spec.Replicas = &opts.Deployment.Replicas
+	}
+
+	// 共享的 data pvc 换成每个副本各自的 pvc，名称为 data-<app>-<n>
+	if opts.Deployment.VolumeMount.Enabled {
+		var volumes []corev1.Volume
+		for _, volume := range template.Spec.Volumes {
+			if volume.Name != "data" {
+				volumes = append(volumes, volume)
+			}
+		}
+		statefulSet.Spec.Template.Spec.Volumes = volumes
+
+		pvc := NewPVC(opts.PVC)
+		statefulSet.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "data",
+					Labels: labels,
+				},
+				Spec: pvc.Spec,
+			},
+		}
+	}
+
+	return statefulSet, nil
+}
+
+func NewHeadlessService(opts ServiceOptions) *corev1.Service {
+	service := newClusterIPService(opts)
+	service.Name = HeadlessServiceName(opts.Name)
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	return service
+}
+
+// 删除 StatefulSet 和它的无头 Service，每个副本的 pvc 由 DeleteStatefulSetPVCs 删除
+func DeleteStatefulSet(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string, quiet bool) error {
+	if err := remove(ctx, clientset.AppsV1().StatefulSets(namespace), name, namespace, "statefulset", quiet); err != nil {
+		return err
+	}
+	return remove(ctx, clientset.CoreV1().Services(namespace), HeadlessServiceName(name), namespace, "service", quiet)
+}
+
+// 删除 volumeClaimTemplates 为每个副本创建的 pvc
+func DeleteStatefulSetPVCs(clientset *kubernetes.Clientset, ctx context.Context, name, namespace string) error {
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: podLabels(name, "")}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pvc resources: %v", err)
+	}
+	for _, pvc := range pvcs.Items {
+		if err := remove(ctx, clientset.CoreV1().PersistentVolumeClaims(namespace), pvc.Name, namespace, "pvc", false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 参照 kubectl rollout status 的判断逻辑
+func statefulSetRolloutStatus(statefulSet *appsv1.StatefulSet) (string, bool, error) {
+	if statefulSet.Status.ObservedGeneration == 0 || statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		return "waiting for statefulset spec update to be observed...", false, nil
+	}
+
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	status := statefulSet.Status
+
+	if status.ReadyReplicas < replicas {
+		return fmt.Sprintf("waiting for %d pods to be ready...", replicas-status.ReadyReplicas), false, nil
+	}
+	if status.UpdateRevision != status.CurrentRevision {
+		return fmt.Sprintf("waiting for statefulset rolling update to complete %d pods at revision %s...", status.UpdatedReplicas, status.UpdateRevision), false, nil
+	}
+	return fmt.Sprintf("statefulset rolling update complete %d pods at revision %s", status.CurrentReplicas, status.CurrentRevision), true, nil
+}